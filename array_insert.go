@@ -0,0 +1,75 @@
+package structemplate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ArrayInsertPosition describes where values are inserted into an array.
+// Only one of Index, Before and After should be set, an empty position appends to the end.
+type ArrayInsertPosition struct {
+	Index  *int   `json:"index,omitempty"`  // 插入位置的下标，0表示插入到数组开头
+	Before string `json:"before,omitempty"` // 插入到第一个匹配的元素之前, 过滤表达式如 `name=app` 或 `?(@.name=="app")`
+	After  string `json:"after,omitempty"`  // 插入到最后一个匹配的元素之后, 过滤表达式同Before
+}
+
+// PrependPosition returns a position inserting values at the beginning of an array.
+func PrependPosition() *ArrayInsertPosition {
+	idx := 0
+	return &ArrayInsertPosition{Index: &idx}
+}
+
+// resolveIndex returns the index in arr at which the values should be inserted.
+func (p *ArrayInsertPosition) resolveIndex(arr []interface{}) (int, error) {
+	switch {
+	case p == nil:
+		return len(arr), nil
+	case p.Index != nil:
+		if *p.Index < 0 || *p.Index > len(arr) {
+			return -1, fmt.Errorf("insert index out of bounds: %d of %d", *p.Index, len(arr))
+		}
+		return *p.Index, nil
+	case len(p.Before) > 0:
+		filter, err := parseInsertFilter(p.Before)
+		if err != nil {
+			return -1, err
+		}
+		for i, elem := range arr {
+			if filter.match(elem) {
+				return i, nil
+			}
+		}
+		return -1, errors.New("no array element matches: " + p.Before)
+	case len(p.After) > 0:
+		filter, err := parseInsertFilter(p.After)
+		if err != nil {
+			return -1, err
+		}
+		for i := len(arr) - 1; i >= 0; i-- {
+			if filter.match(arr[i]) {
+				return i + 1, nil
+			}
+		}
+		return -1, errors.New("no array element matches: " + p.After)
+	default:
+		return len(arr), nil
+	}
+}
+
+// parseInsertFilter parses a filter expression with or without the surrounding brackets.
+func parseInsertFilter(expr string) (*pathFilter, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "[") && strings.HasSuffix(expr, "]") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	if !strings.HasPrefix(expr, "?") && !strings.Contains(expr, "=") {
+		return nil, errors.New("illegal element filter: " + expr)
+	}
+	filter, err := parseFilter(expr)
+	if err != nil {
+		return nil, errors.Wrap(err, "illegal element filter")
+	}
+	return filter, nil
+}
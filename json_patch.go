@@ -0,0 +1,368 @@
+package structemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+)
+
+// JsonPatchOperation is one operation of a JSON Patch (RFC 6902) document.
+// Path and From are JSON Pointers (RFC 6901), e.g. `/spec/template/spec/containers/0/image`.
+// The value of add, replace and test operations is required and always serialized, a nil Value is `null`.
+type JsonPatchOperation struct {
+	Op    string      `json:"op"` // add, remove, replace, move, copy, test
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// jsonPatchOperationFields has the fields of JsonPatchOperation without its json methods
+type jsonPatchOperationFields JsonPatchOperation
+
+// jsonPatchValueRequired reports whether the operation has a value member.
+func jsonPatchValueRequired(op string) bool {
+	return op == JsonPatchOpAdd || op == JsonPatchOpReplace || op == JsonPatchOpTest
+}
+
+// MarshalJSON keeps an explicit `"value": null` of the operations having a value.
+func (op JsonPatchOperation) MarshalJSON() ([]byte, error) {
+	if !jsonPatchValueRequired(op.Op) {
+		return json.Marshal(jsonPatchOperationFields(op))
+	}
+	return json.Marshal(struct {
+		jsonPatchOperationFields
+		Value interface{} `json:"value"`
+	}{jsonPatchOperationFields(op), op.Value})
+}
+
+// UnmarshalJSON rejects add, replace and test operations without value, `"value": null` is a nil Value.
+func (op *JsonPatchOperation) UnmarshalJSON(data []byte) error {
+	var fields struct {
+		jsonPatchOperationFields
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*op = JsonPatchOperation(fields.jsonPatchOperationFields)
+	if fields.Value == nil {
+		if jsonPatchValueRequired(op.Op) {
+			return fmt.Errorf("missing value of json patch operation %s %s", op.Op, op.Path)
+		}
+		return nil
+	}
+	return utiljson.Unmarshal(fields.Value, &op.Value)
+}
+
+// JSON Patch operations
+const (
+	JsonPatchOpAdd     = "add"
+	JsonPatchOpRemove  = "remove"
+	JsonPatchOpReplace = "replace"
+	JsonPatchOpMove    = "move"
+	JsonPatchOpCopy    = "copy"
+	JsonPatchOpTest    = "test"
+)
+
+// ApplyJsonPatch applies a JSON Patch (RFC 6902) document to object.
+// The patch is applied atomically, object is left unmodified if any operation fails, including a failed `test`.
+func ApplyJsonPatch(object map[string]interface{}, patch []JsonPatchOperation) error {
+	var doc interface{} = DeepCopyJSONValue(object)
+	for i, op := range patch {
+		value, err := ToJSONValue(op.Value)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("json patch operation %d", i))
+		}
+		if doc, err = applyJsonPatchOperation(doc, op, value); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("json patch operation %d (%s %s)", i, op.Op, op.Path))
+		}
+	}
+
+	result, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("json patch result is not an object: %T", doc)
+	}
+	for k := range object {
+		delete(object, k)
+	}
+	for k, v := range result {
+		object[k] = v
+	}
+	return nil
+}
+
+func applyJsonPatchOperation(doc interface{}, op JsonPatchOperation, value interface{}) (interface{}, error) {
+	switch op.Op {
+	case JsonPatchOpAdd:
+		return jsonPointerAdd(doc, op.Path, value)
+	case JsonPatchOpRemove:
+		doc, _, err := jsonPointerRemove(doc, op.Path)
+		return doc, err
+	case JsonPatchOpReplace:
+		if _, err := jsonPointerGet(doc, op.Path); err != nil {
+			return nil, err
+		}
+		if op.Path == "" {
+			return value, nil
+		}
+		doc, _, err := jsonPointerRemove(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return jsonPointerAdd(doc, op.Path, value)
+	case JsonPatchOpMove:
+		if op.Path == op.From {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		doc, moved, err := jsonPointerRemove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return jsonPointerAdd(doc, op.Path, moved)
+	case JsonPatchOpCopy:
+		copied, err := jsonPointerGet(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return jsonPointerAdd(doc, op.Path, DeepCopyJSONValue(copied))
+	case JsonPatchOpTest:
+		current, err := jsonPointerGet(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonValueEqual(current, value) {
+			return nil, fmt.Errorf("test failed: value is %v", current)
+		}
+		return doc, nil
+	default:
+		return nil, errors.New("unknown json patch operation: " + op.Op)
+	}
+}
+
+// parseJsonPointer splits a JSON Pointer into unescaped reference tokens, "" refers to the whole document.
+func parseJsonPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.New("json pointer must start with '/': " + pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// parseJsonPointerIndex parses an array index token, '-' refers to the position after the last element.
+func parseJsonPointerIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	if len(token) < 1 || (len(token) > 1 && token[0] == '0') {
+		return -1, errors.New("illegal array index: " + token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return -1, errors.New("illegal array index: " + token)
+	}
+	if idx > length || (!allowEnd && idx == length) {
+		return -1, fmt.Errorf("array index out of bounds: %d", idx)
+	}
+	return idx, nil
+}
+
+func jsonPointerGet(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parseJsonPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	node := doc
+	for _, token := range tokens {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[token]
+			if !ok {
+				return nil, errors.Wrap(ErrFieldNotExist, pointer)
+			}
+			node = v
+		case []interface{}:
+			idx, err := parseJsonPointerIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[idx]
+		default:
+			return nil, errors.Wrap(ErrFieldNotExist, pointer)
+		}
+	}
+	return node, nil
+}
+
+// jsonPointerUpdate applies fn to the container of the last token and writes the returned container back.
+func jsonPointerUpdate(doc interface{}, tokens []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	switch n := doc.(type) {
+	case map[string]interface{}:
+		child, ok := n[tokens[0]]
+		if !ok {
+			return nil, errors.Wrap(ErrFieldNotExist, tokens[0])
+		}
+		newChild, err := jsonPointerUpdate(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[tokens[0]] = newChild
+		return n, nil
+	case []interface{}:
+		idx, err := parseJsonPointerIndex(tokens[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		newChild, err := jsonPointerUpdate(n[idx], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = newChild
+		return n, nil
+	default:
+		return nil, errors.Wrap(ErrFieldNotExist, tokens[0])
+	}
+}
+
+func jsonPointerAdd(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := parseJsonPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) < 1 {
+		return value, nil
+	}
+	return jsonPointerUpdate(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			idx, err := parseJsonPointerIndex(token, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			newArr := make([]interface{}, 0, len(c)+1)
+			newArr = append(newArr, c[:idx]...)
+			newArr = append(newArr, value)
+			return append(newArr, c[idx:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot add a value into %T", container)
+		}
+	})
+}
+
+// jsonPointerRemove removes the value at pointer and returns the updated document and the removed value.
+func jsonPointerRemove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := parseJsonPointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) < 1 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	var removed interface{}
+	doc, err = jsonPointerUpdate(doc, tokens, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, errors.Wrap(ErrFieldNotExist, pointer)
+			}
+			removed = v
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			idx, err := parseJsonPointerIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = c[idx]
+			newArr := make([]interface{}, 0, len(c)-1)
+			newArr = append(newArr, c[:idx]...)
+			return append(newArr, c[idx+1:]...), nil
+		default:
+			return nil, errors.Wrap(ErrFieldNotExist, pointer)
+		}
+	})
+	return doc, removed, err
+}
+
+// BindJsonPatchValue returns a copy of the patch with the `${paramCode}` placeholders replaced by value.
+// A string that is exactly the placeholder is replaced by the value itself keeping its type,
+// placeholders inside longer strings are replaced by the string form of the value.
+// Placeholders in Path and From are escaped as JSON Pointer tokens.
+func BindJsonPatchValue(patch []JsonPatchOperation, paramCode string, value interface{}) ([]JsonPatchOperation, error) {
+	placeholder := "${" + paramCode + "}"
+	valueStr, err := stringifyValue(value)
+	if err != nil {
+		return nil, err
+	}
+	tokenStr := strings.ReplaceAll(strings.ReplaceAll(valueStr, "~", "~0"), "/", "~1")
+
+	bound := make([]JsonPatchOperation, len(patch))
+	for i, op := range patch {
+		opValue, err := ToJSONValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		bound[i] = JsonPatchOperation{
+			Op:    op.Op,
+			Path:  strings.ReplaceAll(op.Path, placeholder, tokenStr),
+			From:  strings.ReplaceAll(op.From, placeholder, tokenStr),
+			Value: bindPlaceholder(opValue, placeholder, value, valueStr),
+		}
+	}
+	return bound, nil
+}
+
+func bindPlaceholder(node interface{}, placeholder string, value interface{}, valueStr string) interface{} {
+	switch n := node.(type) {
+	case string:
+		if n == placeholder {
+			return DeepCopyJSONValue(value)
+		}
+		return strings.ReplaceAll(n, placeholder, valueStr)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			result[strings.ReplaceAll(k, placeholder, valueStr)] = bindPlaceholder(v, placeholder, value, valueStr)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(n))
+		for i, v := range n {
+			result[i] = bindPlaceholder(v, placeholder, value, valueStr)
+		}
+		return result
+	default:
+		return n
+	}
+}
+
+// stringifyValue returns strings as is and the json form of other values, the same way StrSlot params are rendered.
+func stringifyValue(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot convert the value to string")
+	}
+	return string(b), nil
+}
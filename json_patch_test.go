@@ -0,0 +1,125 @@
+package structemplate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+func TestApplyJsonPatch(t *testing.T) {
+	obj := parseObject(t)
+	err := ApplyJsonPatch(obj, []JsonPatchOperation{
+		{Op: JsonPatchOpTest, Path: "/metadata/name", Value: "test-target-tlsroute"},
+		{Op: JsonPatchOpAdd, Path: "/spec/hostnames/0", Value: "first.example.com"},
+		{Op: JsonPatchOpAdd, Path: "/spec/hostnames/-", Value: "last.example.com"},
+		{Op: JsonPatchOpRemove, Path: "/spec/hostnames/1"},
+		{Op: JsonPatchOpReplace, Path: "/spec/parentRefs/0/port", Value: 443},
+		{Op: JsonPatchOpCopy, From: "/metadata/labels", Path: "/metadata/annotations"},
+		{Op: JsonPatchOpMove, From: "/metadata/annotations/istio", Path: "/metadata/annotations/app.io~1gateway"},
+	})
+	if err != nil {
+		t.Logf("Failed apply json patch: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	hostnames, _ := GetValueOfNestedField(obj, "spec.hostnames")
+	if !jsonValueEqual(hostnames, []interface{}{"first.example.com", "hostname2.example.com", "last.example.com"}) {
+		t.Logf("Unexpected hostnames: %v", hostnames)
+		t.FailNow()
+		return
+	}
+	if v, _ := GetValueOfNestedField(obj, "spec.parentRefs[0].port"); v != int64(443) {
+		t.Logf("Unexpected port: %v", v)
+		t.FailNow()
+		return
+	}
+	if v, _ := GetValueOfNestedField(obj, "metadata.annotations['app.io/gateway']"); v != "test-target-gateway" {
+		t.Logf("Unexpected annotation: %v", v)
+		t.FailNow()
+		return
+	}
+
+	// failed test leaves the object unmodified
+	err = ApplyJsonPatch(obj, []JsonPatchOperation{
+		{Op: JsonPatchOpReplace, Path: "/metadata/name", Value: "modified"},
+		{Op: JsonPatchOpTest, Path: "/metadata/namespace", Value: "other"},
+	})
+	if err == nil {
+		t.Log("Expected error of failed test does not occurred")
+		t.FailNow()
+		return
+	}
+	if v, _ := GetValueOfNestedField(obj, "metadata.name"); v != "test-target-tlsroute" {
+		t.Logf("Object modified by a failed patch: %v", v)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderJsonPatchParam(t *testing.T) {
+	obj := parseObject(t)
+	unstructuredObj := unstructured.Unstructured{Object: obj}
+	patchParam := TemplateDynamicParam{
+		ParamCode:          "GATEWAY",
+		ParamType:          ParamTypeJsonPatch,
+		ValueInjectTargets: []JsonPathParamTarget{{}},
+		JsonPatch: []JsonPatchOperation{
+			{Op: JsonPatchOpTest, Path: "/spec/parentRefs/0/kind", Value: "Gateway"},
+			{Op: JsonPatchOpReplace, Path: "/spec/parentRefs/0", Value: map[string]interface{}{
+				"name":      "${GATEWAY}",
+				"namespace": "gateways",
+			}},
+			{Op: JsonPatchOpAdd, Path: "/metadata/labels/${GATEWAY}", Value: "gw-${GATEWAY}"},
+		},
+	}
+	err := RenderJsonPathParamForUnstructuredObj(&unstructuredObj, &patchParam, &patchParam.ValueInjectTargets[0], "internal")
+	if err != nil {
+		t.Logf("Failed render JsonPatch param: %+v", err)
+		t.FailNow()
+		return
+	}
+	parentRef, _ := GetValueOfNestedField(obj, "spec.parentRefs[0]")
+	if !jsonValueEqual(parentRef, map[string]interface{}{"name": "internal", "namespace": "gateways"}) {
+		t.Logf("Unexpected parentRef: %v", parentRef)
+		t.FailNow()
+		return
+	}
+	if v, _ := GetValueOfNestedField(obj, "metadata.labels.internal"); v != "gw-internal" {
+		t.Logf("Unexpected label: %v", v)
+		t.FailNow()
+		return
+	}
+}
+
+func TestJsonPatchOperation_NullValue(t *testing.T) {
+	patch := []JsonPatchOperation{
+		{Op: JsonPatchOpAdd, Path: "/metadata/annotations/note", Value: nil},
+		{Op: JsonPatchOpRemove, Path: "/metadata/labels"},
+	}
+	b, err := json.Marshal(patch)
+	if err != nil || string(b) != `[{"op":"add","path":"/metadata/annotations/note","value":null},{"op":"remove","path":"/metadata/labels"}]` {
+		t.Logf("Unexpected json: %s, %v", b, err)
+		t.FailNow()
+		return
+	}
+
+	var decoded []JsonPatchOperation
+	if err := sigsyaml.Unmarshal([]byte("- op: test\n  path: /spec/replicas\n  value: null\n- op: replace\n  path: /spec/replicas\n  value: 3\n"), &decoded); err != nil {
+		t.Logf("Failed decode patch: %v", err)
+		t.FailNow()
+		return
+	}
+	if len(decoded) != 2 || decoded[0].Value != nil || decoded[1].Value != int64(3) {
+		t.Logf("Unexpected patch: %#v", decoded)
+		t.FailNow()
+		return
+	}
+	if err := json.Unmarshal([]byte(`[{"op":"add","path":"/a"}]`), &decoded); err == nil {
+		t.Log("Expected error of missing value does not occurred")
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,750 @@
+package structemplate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JsonPath is a compiled json path expression used to locate fields of a structured object.
+//
+// Supported syntax:
+//
+//	spec.parentRefs[0].name                      keys separated by '.', array index in brackets
+//	$.spec.parentRefs[0].name                    optional '$' root and leading '.'
+//	.spec.parentRefs.[0].name                    legacy form with the index as its own segment
+//	metadata.annotations['app.kubernetes.io/name']  bracket-quoted key, single or double quotes
+//	metadata.labels.app\.kubernetes\.io/name     '\' escapes '.', '[', ']' and '\' in a plain key
+//	spec.containers[*].imagePullPolicy           '[*]' or '.*' matches every element of an array or every value of a map
+//	..resources.limits                           '..key' matches the key at any depth (recursive descent)
+//	spec.containers[?(@.name=="app")].image      filter array elements by a field value, '==' and '!=' are supported
+//	spec.containers[name=app].image              shorthand filter comparing the string form of a field
+//
+// Paths containing wildcards, recursive descent or filters may match multiple nodes (see IsMultiMatch).
+// Wildcards, recursive descent and filters only match existing nodes and never create them,
+// the plain key and index segments after them create missing fields within every matched node.
+type JsonPath struct {
+	expr     string
+	segments []pathSegment
+	multi    bool
+}
+
+type pathSegmentType int
+
+const (
+	segmentKey pathSegmentType = iota
+	segmentIndex
+	segmentWildcard  // [*]
+	segmentRecursive // ..key
+	segmentFilter    // [?(@.name=="app")] or [name=app]
+)
+
+type pathSegment struct {
+	Type   pathSegmentType
+	Key    string
+	Index  int
+	Filter *pathFilter
+}
+
+// isMulti reports whether the segment may match any number of existing nodes.
+func (s pathSegment) isMulti() bool {
+	return s.Type == segmentWildcard || s.Type == segmentRecursive || s.Type == segmentFilter
+}
+
+// ErrFieldNotExist is the cause of errors returned when a field located by a path does not exist.
+var ErrFieldNotExist = errors.New("field does not exist")
+
+// ErrPathNotMatched is the cause of errors returned when a multi-match path locates no node to modify.
+var ErrPathNotMatched = errors.New("path matches nothing")
+
+// maxCachedJsonPaths bounds the count of compiled json paths kept by compileJsonPathCached
+const maxCachedJsonPaths = 1024
+
+// compiled json paths, the expressions come from param definitions so the cache is bounded
+var compiledJsonPaths = newLRUCache[*JsonPath](maxCachedJsonPaths)
+
+// CompileJsonPath parses a json path expression into a reusable JsonPath.
+func CompileJsonPath(expr string) (*JsonPath, error) {
+	p := &JsonPath{expr: expr}
+	parser := jsonPathParser{expr: expr}
+	segments, err := parser.parse()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid json path: "+expr)
+	}
+	p.segments = segments
+	for _, seg := range segments {
+		if seg.isMulti() {
+			p.multi = true
+		}
+	}
+	return p, nil
+}
+
+// MustCompileJsonPath is like CompileJsonPath but panics if the expression cannot be parsed.
+func MustCompileJsonPath(expr string) *JsonPath {
+	p, err := CompileJsonPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// compileJsonPathCached compiles a json path expression and reuses the result,
+// the least recently used paths are dropped beyond maxCachedJsonPaths.
+func compileJsonPathCached(expr string) (*JsonPath, error) {
+	if p, ok := compiledJsonPaths.get(expr); ok {
+		return p, nil
+	}
+	p, err := CompileJsonPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	compiledJsonPaths.add(expr, p)
+	return p, nil
+}
+
+// Child returns a new JsonPath locating the `key` field of the object located by p.
+func (p *JsonPath) Child(key string) *JsonPath {
+	segments := make([]pathSegment, len(p.segments), len(p.segments)+1)
+	copy(segments, p.segments)
+	child := &JsonPath{segments: append(segments, pathSegment{Type: segmentKey, Key: key}), multi: p.multi}
+	child.expr = child.String()
+	return child
+}
+
+// IsMultiMatch reports whether the path contains wildcards, recursive descent or filters and may match multiple nodes.
+func (p *JsonPath) IsMultiMatch() bool {
+	return p.multi
+}
+
+// IsRoot reports whether the path locates the object itself.
+func (p *JsonPath) IsRoot() bool {
+	return len(p.segments) < 1
+}
+
+// String returns the normalized form of the expression, e.g. `.metadata.annotations['a.b/c']`.
+func (p *JsonPath) String() string {
+	if p.IsRoot() {
+		return "."
+	}
+	b := strings.Builder{}
+	for _, seg := range p.segments {
+		b.WriteString(seg.String())
+	}
+	return b.String()
+}
+
+func (s pathSegment) String() string {
+	switch s.Type {
+	case segmentIndex:
+		return fmt.Sprintf("[%d]", s.Index)
+	case segmentWildcard:
+		return "[*]"
+	case segmentFilter:
+		return "[" + s.Filter.expr + "]"
+	case segmentRecursive:
+		key := formatKey(s.Key)
+		if strings.HasPrefix(key, "[") {
+			return ".." + key
+		}
+		return "." + key
+	default:
+		return formatKey(s.Key)
+	}
+}
+
+// formatKey formats a key segment as `.key` or `['key']` when the key contains special characters.
+func formatKey(key string) string {
+	if strings.ContainsAny(key, ".[]'\"\\* ") || len(key) < 1 {
+		return "['" + strings.ReplaceAll(strings.ReplaceAll(key, "\\", "\\\\"), "'", "\\'") + "']"
+	}
+	return "." + key
+}
+
+// Get returns a deep copy of the value located by the path.
+// A missing last field results in a nil value, missing parent fields result in an error.
+// For multi-match paths a []interface{} of all matched values is returned, see GetAll.
+func (p *JsonPath) Get(object map[string]interface{}) (interface{}, error) {
+	if p.multi {
+		values, err := p.GetAll(object)
+		if err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+	refs, err := p.resolve(object, false)
+	if err != nil {
+		return nil, err
+	}
+	v, _ := refs[0].get()
+	return DeepCopyJSONValue(v), nil
+}
+
+// GetAll returns deep copies of all the values matched by the path in document order.
+// Missing fields below multi-match segments are skipped.
+func (p *JsonPath) GetAll(object map[string]interface{}) ([]interface{}, error) {
+	refs, err := p.resolve(object, false)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		if v, exists := ref.get(); exists {
+			values = append(values, DeepCopyJSONValue(v))
+		}
+	}
+	return values, nil
+}
+
+// Set sets the value located by the path, missing parent fields are created automatically.
+// For multi-match paths the value is set on every matched node, an error caused by ErrPathNotMatched
+// is returned when nothing is matched.
+// When appendArray is true the located field must be an array (or missing) and value is appended to it,
+// a slice value is appended element by element.
+func (p *JsonPath) Set(object map[string]interface{}, value interface{}, appendArray bool) error {
+	if appendArray {
+		return p.Insert(object, value, nil)
+	}
+	if p.IsRoot() {
+		return errors.New("cannot set the root object")
+	}
+	refs, err := p.resolveTargets(object)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		ref.set(value)
+	}
+	return nil
+}
+
+// Insert inserts value into the array located by the path at the position, a nil position appends to the end.
+// The located field must be an array or missing, a slice value is inserted element by element.
+// An error caused by ErrPathNotMatched is returned when a multi-match path matches nothing.
+func (p *JsonPath) Insert(object map[string]interface{}, value interface{}, position *ArrayInsertPosition) error {
+	if p.IsRoot() {
+		return errors.New("cannot insert into the root object")
+	}
+	refs, err := p.resolveTargets(object)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err := ref.insertValues(value, position); err != nil {
+			return errors.Wrap(err, "insert into array failed: "+ref.path)
+		}
+	}
+	return nil
+}
+
+// Remove removes the nodes located by the path and returns the count of removed nodes.
+// Map fields are deleted and array elements are removed with the following elements shifted forward.
+// An error caused by ErrFieldNotExist is returned if a parent field of a single-match path does not exist.
+func (p *JsonPath) Remove(object map[string]interface{}) (int, error) {
+	if p.IsRoot() {
+		return 0, errors.New("cannot remove the root object")
+	}
+	refs, err := p.resolve(object, false)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	arrayElems := make(map[*nodeRef][]int)
+	arrayOrder := make([]*nodeRef, 0)
+	for _, ref := range refs {
+		switch c := ref.container.(type) {
+		case map[string]interface{}:
+			if _, exists := c[ref.seg.Key]; exists {
+				delete(c, ref.seg.Key)
+				removed++
+			}
+		case []interface{}:
+			// removed together later to keep the indexes valid
+			if _, ok := arrayElems[ref.up]; !ok {
+				arrayOrder = append(arrayOrder, ref.up)
+			}
+			arrayElems[ref.up] = append(arrayElems[ref.up], ref.seg.Index)
+		}
+	}
+
+	for _, arrRef := range arrayOrder {
+		node, _ := arrRef.get()
+		arr, ok := node.([]interface{})
+		if !ok {
+			continue
+		}
+		drop := make(map[int]bool)
+		for _, idx := range arrayElems[arrRef] {
+			drop[idx] = true
+		}
+		kept := make([]interface{}, 0, len(arr))
+		for i, elem := range arr {
+			if !drop[i] {
+				kept = append(kept, elem)
+			}
+		}
+		removed += len(arr) - len(kept)
+		arrRef.set(kept)
+	}
+	return removed, nil
+}
+
+// nodeRef references a node by its container and the key or index within the container,
+// so that the node can be replaced in place.
+type nodeRef struct {
+	up        *nodeRef
+	container interface{} // map[string]interface{} or []interface{}
+	seg       pathSegment
+	path      string
+	isRoot    bool
+}
+
+func (r *nodeRef) get() (interface{}, bool) {
+	if r.isRoot {
+		return r.container, true
+	}
+	switch c := r.container.(type) {
+	case map[string]interface{}:
+		v, ok := c[r.seg.Key]
+		return v, ok
+	case []interface{}:
+		return c[r.seg.Index], true
+	}
+	return nil, false
+}
+
+func (r *nodeRef) set(value interface{}) {
+	if r.isRoot {
+		return
+	}
+	switch c := r.container.(type) {
+	case map[string]interface{}:
+		c[r.seg.Key] = value
+	case []interface{}:
+		c[r.seg.Index] = value
+	}
+}
+
+func (r *nodeRef) insertValues(value interface{}, position *ArrayInsertPosition) error {
+	current, _ := r.get()
+	if current == nil {
+		current = make([]interface{}, 0)
+	}
+	arr, ok := current.([]interface{})
+	if !ok {
+		return fmt.Errorf("last node is not an array: %T", current)
+	}
+
+	idx, err := position.resolveIndex(arr)
+	if err != nil {
+		return err
+	}
+	values := toInterfaceSlice(value)
+	newArr := make([]interface{}, 0, len(arr)+len(values))
+	newArr = append(newArr, arr[:idx]...)
+	newArr = append(newArr, values...)
+	newArr = append(newArr, arr[idx:]...)
+	r.set(newArr)
+	return nil
+}
+
+// fieldNames returns the names of the map fields leading to the node, array indexes are skipped.
+func (r *nodeRef) fieldNames() []string {
+	names := make([]string, 0)
+	for ref := r; ref != nil && !ref.isRoot; ref = ref.up {
+		if _, ok := ref.container.(map[string]interface{}); ok {
+			names = append(names, ref.seg.Key)
+		}
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names
+}
+
+// toInterfaceSlice converts a slice of any type to []interface{}, other values are wrapped in a single element slice.
+func toInterfaceSlice(value interface{}) []interface{} {
+	if arr, ok := value.([]interface{}); ok {
+		return arr
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice {
+		return []interface{}{value}
+	}
+	arr := make([]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		arr[i] = v.Index(i).Interface()
+	}
+	return arr
+}
+
+// resolve locates the nodes referenced by the path.
+// With create set missing or null parent nodes are created according to the type of the next segment.
+// Once a multi-match segment has been traversed, nodes that cannot be indexed by the
+// following segments are skipped instead of resulting in an error.
+func (p *JsonPath) resolve(object map[string]interface{}, create bool) ([]*nodeRef, error) {
+	cursor := []*nodeRef{{container: object, isRoot: true}}
+	if p.IsRoot() {
+		return cursor, nil
+	}
+
+	multi := false
+	for _, seg := range p.segments {
+		next := make([]*nodeRef, 0, len(cursor))
+		for _, ref := range cursor {
+			node, exists := ref.get()
+			if node == nil {
+				if seg.isMulti() || multi && !create {
+					continue
+				}
+				if !create {
+					if !exists {
+						return nil, errors.Wrap(ErrFieldNotExist, ref.path)
+					}
+					return nil, errors.Wrap(ErrFieldNotExist, "field is null: "+ref.path)
+				}
+				node = newContainerFor(seg)
+				ref.set(node)
+			}
+
+			children, err := childRefs(ref, node, seg)
+			if err != nil {
+				if multi {
+					continue
+				}
+				return nil, err
+			}
+			next = append(next, children...)
+		}
+		cursor = next
+		if seg.isMulti() {
+			multi = true
+		}
+	}
+	return cursor, nil
+}
+
+// resolveTargets resolves the nodes to modify, missing parent fields are created.
+// Unlike resolve, matching no node is an error.
+func (p *JsonPath) resolveTargets(object map[string]interface{}) ([]*nodeRef, error) {
+	refs, err := p.resolve(object, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) < 1 {
+		return nil, errors.Wrap(ErrPathNotMatched, p.String())
+	}
+	return refs, nil
+}
+
+// childRefs returns the references of the children of node matched by seg.
+func childRefs(ref *nodeRef, node interface{}, seg pathSegment) ([]*nodeRef, error) {
+	switch seg.Type {
+	case segmentKey:
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("element cannot be indexed by key: %s%s is %T", ref.path, seg.String(), node)
+		}
+		return []*nodeRef{{up: ref, container: m, seg: seg, path: ref.path + seg.String()}}, nil
+	case segmentIndex:
+		arr, ok := node.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("element is not an array: %s%s is %T", ref.path, seg.String(), node)
+		}
+		if seg.Index >= len(arr) {
+			return nil, errors.Wrap(ErrFieldNotExist, "array index out of bounds: "+ref.path+seg.String())
+		}
+		return []*nodeRef{{up: ref, container: arr, seg: seg, path: ref.path + seg.String()}}, nil
+	case segmentWildcard:
+		switch n := node.(type) {
+		case map[string]interface{}:
+			return mapChildRefs(ref, n), nil
+		case []interface{}:
+			return arrayChildRefs(ref, n), nil
+		default:
+			return nil, fmt.Errorf("wildcard cannot be applied to %s: %T", ref.path, node)
+		}
+	case segmentRecursive:
+		refs := make([]*nodeRef, 0)
+		collectRecursiveRefs(ref, node, seg.Key, &refs)
+		return refs, nil
+	case segmentFilter:
+		arr, ok := node.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("filter can only be applied to arrays: %s is %T", ref.path, node)
+		}
+		refs := make([]*nodeRef, 0)
+		for _, child := range arrayChildRefs(ref, arr) {
+			if v, _ := child.get(); seg.Filter.match(v) {
+				refs = append(refs, child)
+			}
+		}
+		return refs, nil
+	}
+	return nil, fmt.Errorf("unknown path segment type: %d", seg.Type)
+}
+
+// collectRecursiveRefs collects the `key` fields of node and all its descendants in document order.
+func collectRecursiveRefs(ref *nodeRef, node interface{}, key string, refs *[]*nodeRef) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keySeg := pathSegment{Type: segmentKey, Key: key}
+		if _, ok := n[key]; ok {
+			*refs = append(*refs, &nodeRef{up: ref, container: n, seg: keySeg, path: ref.path + keySeg.String()})
+		}
+		for _, child := range mapChildRefs(ref, n) {
+			v, _ := child.get()
+			collectRecursiveRefs(child, v, key, refs)
+		}
+	case []interface{}:
+		for _, child := range arrayChildRefs(ref, n) {
+			v, _ := child.get()
+			collectRecursiveRefs(child, v, key, refs)
+		}
+	}
+}
+
+// mapChildRefs returns the references of all values of a map ordered by key.
+func mapChildRefs(ref *nodeRef, m map[string]interface{}) []*nodeRef {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	refs := make([]*nodeRef, 0, len(keys))
+	for _, k := range keys {
+		seg := pathSegment{Type: segmentKey, Key: k}
+		refs = append(refs, &nodeRef{up: ref, container: m, seg: seg, path: ref.path + seg.String()})
+	}
+	return refs
+}
+
+// arrayChildRefs returns the references of all elements of an array.
+func arrayChildRefs(ref *nodeRef, arr []interface{}) []*nodeRef {
+	refs := make([]*nodeRef, 0, len(arr))
+	for i := range arr {
+		seg := pathSegment{Type: segmentIndex, Index: i}
+		refs = append(refs, &nodeRef{up: ref, container: arr, seg: seg, path: ref.path + seg.String()})
+	}
+	return refs
+}
+
+// newContainerFor creates an empty container that can be indexed by seg.
+func newContainerFor(seg pathSegment) interface{} {
+	if seg.Type == segmentIndex {
+		return make([]interface{}, seg.Index+1)
+	}
+	return make(map[string]interface{})
+}
+
+type jsonPathParser struct {
+	expr string
+	pos  int
+}
+
+func (ps *jsonPathParser) parse() ([]pathSegment, error) {
+	segments := make([]pathSegment, 0)
+	expr := strings.TrimSpace(ps.expr)
+	ps.expr = expr
+	if strings.HasPrefix(expr, "$") {
+		ps.pos++
+	}
+
+	// key without leading '.'
+	if ps.pos < len(expr) && expr[ps.pos] != '.' && expr[ps.pos] != '[' {
+		seg, err := ps.parseDotSegment()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+
+	for ps.pos < len(expr) {
+		switch expr[ps.pos] {
+		case '.':
+			ps.pos++
+			if ps.pos >= len(expr) {
+				// tolerate a trailing '.'
+				return segments, nil
+			}
+			if expr[ps.pos] == '.' {
+				seg, err := ps.parseRecursive()
+				if err != nil {
+					return nil, err
+				}
+				segments = append(segments, seg)
+				continue
+			}
+			if expr[ps.pos] == '[' {
+				// legacy `.[0]`
+				continue
+			}
+			seg, err := ps.parseDotSegment()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+		case '[':
+			seg, err := ps.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, seg)
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", expr[ps.pos], ps.pos)
+		}
+	}
+	return segments, nil
+}
+
+// parseDotSegment parses a plain key or a '*' wildcard following a '.'.
+func (ps *jsonPathParser) parseDotSegment() (pathSegment, error) {
+	if ps.expr[ps.pos] == '*' && (ps.pos+1 >= len(ps.expr) || ps.expr[ps.pos+1] == '.' || ps.expr[ps.pos+1] == '[') {
+		ps.pos++
+		return pathSegment{Type: segmentWildcard}, nil
+	}
+	key, err := ps.parsePlainKey()
+	if err != nil {
+		return pathSegment{}, err
+	}
+	if len(key) < 1 {
+		return pathSegment{}, fmt.Errorf("empty key at position %d", ps.pos)
+	}
+	return pathSegment{Type: segmentKey, Key: key}, nil
+}
+
+// parseRecursive parses `..key` or `..['key']`, the position is at the second '.'.
+func (ps *jsonPathParser) parseRecursive() (pathSegment, error) {
+	start := ps.pos - 1
+	ps.pos++
+	if ps.pos >= len(ps.expr) {
+		return pathSegment{}, fmt.Errorf("missing key of recursive descent at position %d", start)
+	}
+	var seg pathSegment
+	var err error
+	if ps.expr[ps.pos] == '[' {
+		seg, err = ps.parseBracket()
+	} else {
+		seg, err = ps.parseDotSegment()
+	}
+	if err != nil {
+		return pathSegment{}, err
+	}
+	if seg.Type != segmentKey {
+		return pathSegment{}, fmt.Errorf("recursive descent only supports keys at position %d", start)
+	}
+	return pathSegment{Type: segmentRecursive, Key: seg.Key}, nil
+}
+
+// parsePlainKey reads a key until an unescaped '.' or '['.
+func (ps *jsonPathParser) parsePlainKey() (string, error) {
+	b := strings.Builder{}
+	for ps.pos < len(ps.expr) {
+		c := ps.expr[ps.pos]
+		switch c {
+		case '\\':
+			if ps.pos+1 >= len(ps.expr) {
+				return "", fmt.Errorf("dangling escape at position %d", ps.pos)
+			}
+			b.WriteByte(ps.expr[ps.pos+1])
+			ps.pos += 2
+			continue
+		case '.', '[':
+			return b.String(), nil
+		case ']':
+			return "", fmt.Errorf("unexpected ']' at position %d", ps.pos)
+		}
+		b.WriteByte(c)
+		ps.pos++
+	}
+	return b.String(), nil
+}
+
+// parseBracket parses `[0]`, `['key']` or `["key"]` at the current position.
+func (ps *jsonPathParser) parseBracket() (pathSegment, error) {
+	start := ps.pos
+	ps.pos++ // '['
+	ps.skipSpaces()
+	if ps.pos >= len(ps.expr) {
+		return pathSegment{}, fmt.Errorf("unclosed '[' at position %d", start)
+	}
+
+	var seg pathSegment
+	if q := ps.expr[ps.pos]; q == '\'' || q == '"' {
+		key, err := ps.parseQuoted(q)
+		if err != nil {
+			return pathSegment{}, err
+		}
+		seg = pathSegment{Type: segmentKey, Key: key}
+	} else {
+		end := findClosingBracket(ps.expr[ps.pos:])
+		if end < 0 {
+			return pathSegment{}, fmt.Errorf("unclosed '[' at position %d", start)
+		}
+		content := strings.TrimSpace(ps.expr[ps.pos : ps.pos+end])
+		ps.pos += end
+		var err error
+		if seg, err = parseBracketContent(content, start); err != nil {
+			return pathSegment{}, err
+		}
+	}
+
+	ps.skipSpaces()
+	if ps.pos >= len(ps.expr) || ps.expr[ps.pos] != ']' {
+		return pathSegment{}, fmt.Errorf("unclosed '[' at position %d", start)
+	}
+	ps.pos++
+	return seg, nil
+}
+
+// parseBracketContent parses the unquoted content of a bracket: '*', a filter or an array index.
+func parseBracketContent(content string, pos int) (pathSegment, error) {
+	if content == "*" {
+		return pathSegment{Type: segmentWildcard}, nil
+	}
+	if strings.HasPrefix(content, "?") || strings.Contains(content, "=") {
+		filter, err := parseFilter(content)
+		if err != nil {
+			return pathSegment{}, errors.Wrap(err, fmt.Sprintf("illegal filter at position %d", pos))
+		}
+		return pathSegment{Type: segmentFilter, Filter: filter}, nil
+	}
+	idx, err := strconv.Atoi(content)
+	if err != nil || idx < 0 {
+		return pathSegment{}, fmt.Errorf("illegal array index '%s' at position %d", content, pos)
+	}
+	return pathSegment{Type: segmentIndex, Index: idx}, nil
+}
+
+// parseQuoted reads a quoted string starting at the current position, '\' escapes the next character.
+func (ps *jsonPathParser) parseQuoted(quote byte) (string, error) {
+	start := ps.pos
+	ps.pos++
+	b := strings.Builder{}
+	for ps.pos < len(ps.expr) {
+		c := ps.expr[ps.pos]
+		switch {
+		case c == '\\' && ps.pos+1 < len(ps.expr):
+			b.WriteByte(ps.expr[ps.pos+1])
+			ps.pos += 2
+			continue
+		case c == quote:
+			ps.pos++
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+		ps.pos++
+	}
+	return "", fmt.Errorf("unclosed quote at position %d", start)
+}
+
+func (ps *jsonPathParser) skipSpaces() {
+	for ps.pos < len(ps.expr) && ps.expr[ps.pos] == ' ' {
+		ps.pos++
+	}
+}
@@ -0,0 +1,207 @@
+package structemplate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// pathFilter selects array elements by comparing a field of the element with a literal value.
+type pathFilter struct {
+	expr  string      // original expression inside the brackets
+	path  *JsonPath   // path of the compared field relative to the element, root means the element itself
+	op    string      // "==", "!=" or "" for an existence check
+	value interface{} // literal to compare with
+	loose bool        // compare string forms of scalar values, used by the `[key=value]` shorthand
+}
+
+// parseFilter parses `?(@.name=="app")`, `?(@.name)`, `?(@ != 'x')` or the shorthand `name=app` (`name==app`)
+// and `name!=app`. Other operators like `<`, `>=` or `~=` are rejected in both forms.
+func parseFilter(content string) (*pathFilter, error) {
+	f := &pathFilter{expr: content}
+	if !strings.HasPrefix(content, "?") {
+		// shorthand: key=value, key!=value
+		idx := strings.IndexByte(content, '=')
+		if idx < 0 {
+			return nil, errors.New("missing operator of filter: " + content)
+		}
+		op, left, right := "==", content[:idx], strings.TrimSpace(strings.TrimPrefix(content[idx+1:], "="))
+		if strings.HasSuffix(left, "!") {
+			op, left = "!=", left[:len(left)-1]
+		} else if i := strings.IndexAny(left, "<>~!"); i >= 0 {
+			return nil, fmt.Errorf("unsupported operator of filter %s: %s", content, strings.TrimSpace(left[i:])+"=")
+		}
+		left = strings.TrimSpace(left)
+		if len(left) < 1 {
+			return nil, errors.New("missing field name of filter: " + content)
+		}
+		if unquoted, err := unquoteLiteral(right); err == nil {
+			right = unquoted
+		}
+		path, err := CompileJsonPath(left)
+		if err != nil {
+			return nil, err
+		}
+		f.path, f.op, f.value, f.loose = path, op, right, true
+		return f, f.validate()
+	}
+
+	body := strings.TrimSpace(content[1:])
+	if strings.HasPrefix(body, "(") && strings.HasSuffix(body, ")") {
+		body = strings.TrimSpace(body[1 : len(body)-1])
+	}
+	if !strings.HasPrefix(body, "@") {
+		return nil, errors.New("filter must start with '@': " + content)
+	}
+
+	left, op, right, err := splitFilterOperator(body)
+	if err != nil {
+		return nil, err
+	}
+	path, err := CompileJsonPath(strings.TrimPrefix(left, "@"))
+	if err != nil {
+		return nil, err
+	}
+	f.path, f.op = path, op
+	if len(op) > 0 {
+		if f.value, err = parseFilterLiteral(right); err != nil {
+			return nil, err
+		}
+	}
+	return f, f.validate()
+}
+
+func (f *pathFilter) validate() error {
+	if f.path.IsMultiMatch() {
+		return errors.New("field path of a filter cannot match multiple nodes: " + f.expr)
+	}
+	return nil
+}
+
+// splitFilterOperator splits `@.a == "b"` by the first comparison operator outside quotes,
+// operators other than `==` and `!=` are rejected.
+func splitFilterOperator(body string) (string, string, string, error) {
+	var quote byte
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case (c == '=' || c == '!') && i+1 < len(body) && body[i+1] == '=':
+			return strings.TrimSpace(body[:i]), body[i : i+2], strings.TrimSpace(body[i+2:]), nil
+		case strings.IndexByte("<>~!=", c) >= 0:
+			op := body[i : i+1]
+			if i+1 < len(body) && body[i+1] == '=' {
+				op = body[i : i+2]
+			}
+			return "", "", "", fmt.Errorf("unsupported operator of filter %s: %s", body, op)
+		}
+	}
+	return strings.TrimSpace(body), "", "", nil
+}
+
+// parseFilterLiteral parses a quoted string, number, true, false or null.
+func parseFilterLiteral(literal string) (interface{}, error) {
+	switch literal {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if s, err := unquoteLiteral(literal); err == nil {
+		return s, nil
+	}
+	if i, err := strconv.ParseInt(literal, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(literal, 64); err == nil {
+		return f, nil
+	}
+	return nil, errors.New("illegal filter value: " + literal)
+}
+
+// unquoteLiteral removes single or double quotes around a string, '\' escapes the next character.
+func unquoteLiteral(literal string) (string, error) {
+	if len(literal) < 2 || (literal[0] != '\'' && literal[0] != '"') || literal[len(literal)-1] != literal[0] {
+		return "", errors.New("not a quoted string: " + literal)
+	}
+	ps := jsonPathParser{expr: literal}
+	s, err := ps.parseQuoted(literal[0])
+	if err != nil || ps.pos != len(literal) {
+		return "", errors.New("not a quoted string: " + literal)
+	}
+	return s, nil
+}
+
+func (f *pathFilter) match(elem interface{}) bool {
+	v, exists := elem, true
+	if !f.path.IsRoot() {
+		m, ok := elem.(map[string]interface{})
+		if !ok {
+			return f.op == "!="
+		}
+		refs, err := f.path.resolve(m, false)
+		if err != nil {
+			return f.op == "!="
+		}
+		v, exists = refs[0].get()
+	}
+
+	switch f.op {
+	case "==":
+		return exists && f.equals(v)
+	case "!=":
+		return !exists || !f.equals(v)
+	default:
+		return exists && v != nil
+	}
+}
+
+func (f *pathFilter) equals(v interface{}) bool {
+	if f.loose {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			return false
+		}
+		return fmt.Sprint(v) == f.value
+	}
+	return jsonValueEqual(v, f.value)
+}
+
+// findClosingBracket returns the index of the ']' closing a bracket whose content starts at expr[0],
+// quoted strings and nested brackets are skipped. -1 is returned if the bracket is not closed.
+func findClosingBracket(expr string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
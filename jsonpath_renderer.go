@@ -1,20 +1,26 @@
 package structemplate
 
 import (
-	"fmt"
-	"reflect"
-	"strconv"
-
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-// RenderJsonPathParams 为一个Unstructured对象渲染一组JsonPath param
+// RenderJsonPathParams 为一个Unstructured对象渲染一组JsonPath param, JsonPatch类型参数同样在此处理
+// 不满足VisibleIf条件的参数被跳过, 不注入其值或默认值
 func RenderJsonPathParams(objsMap map[schema.GroupVersionKind][]*unstructured.Unstructured, paramsDef []TemplateDynamicParam, valuesMap map[string]interface{}) error {
-	var err error = nil
+	paramsDef, err := ActiveParams(paramsDef, valuesMap)
+	if err != nil {
+		return err
+	}
+	return renderJsonPathParams(objsMap, paramsDef, valuesMap)
+}
+
+// renderJsonPathParams renders the params resolved by ActiveParams
+func renderJsonPathParams(objsMap map[schema.GroupVersionKind][]*unstructured.Unstructured, paramsDef []TemplateDynamicParam, valuesMap map[string]interface{}) error {
+	var err error
 	for _, param := range paramsDef {
-		if param.ParamType != ParamTypeJsonPath {
+		if param.ParamType != ParamTypeJsonPath && param.ParamType != ParamTypeJsonPatch {
 			// skip non-JsonPath type params
 			continue
 		}
@@ -29,21 +35,40 @@ func RenderJsonPathParams(objsMap map[schema.GroupVersionKind][]*unstructured.Un
 				if param.Optional {
 					continue
 				}
-				return errors.New("必填参数缺失:" + param.ParamCode)
+				return &ParamValueError{Code: ErrCodeRequired, ParamCode: param.ParamCode}
+			}
+			if value, err = CoerceParamValue(&param, value); err != nil {
+				return err
 			}
-			err = RenderObjsWithOneJsonPathParam(objsMap[gvkTarget.TargetGVK], &param, &gvkTarget, value)
-			if err != nil {
-				break
+			if err := RenderObjsWithOneJsonPathParam(objsMap[gvkTarget.TargetGVK], &param, &gvkTarget, value); err != nil {
+				return err
 			}
 		}
 	}
-	return err
+	return nil
 }
 
+// RenderObjsWithOneJsonPathParam 为一组同GVK的对象渲染一个参数的一个注入目标，仅处理匹配目标选择器的对象
+// 非可选参数的注入目标没有匹配到任何对象，或多匹配路径在对象中没有匹配到任何字段时返回错误
 func RenderObjsWithOneJsonPathParam(objs []*unstructured.Unstructured, paramDef *TemplateDynamicParam, paramPath *JsonPathParamTarget, value interface{}) error {
-	var err error = nil
-	for _, obj := range objs {
+	targetObjs, err := SelectTargetObjects(objs, paramPath)
+	if err != nil {
+		return err
+	}
+	if len(targetObjs) < 1 {
+		if paramDef.Optional {
+			return nil
+		}
+		return &ParamValueError{Code: ErrCodeTargetNotMatched, ParamCode: paramDef.ParamCode, Target: paramPath, JsonPath: paramPath.ParamJsonPath}
+	}
+
+	for _, obj := range targetObjs {
 		if err = RenderJsonPathParamForUnstructuredObj(obj, paramDef, paramPath, value); err != nil {
+			// 可选参数的过滤器或通配符路径没有匹配到任何字段时跳过
+			if paramDef.Optional && errors.Cause(err) == ErrPathNotMatched {
+				err = nil
+				continue
+			}
 			break
 		}
 	}
@@ -52,8 +77,36 @@ func RenderObjsWithOneJsonPathParam(objs []*unstructured.Unstructured, paramDef
 
 // RenderJsonPathParamForUnstructuredObj 为一个Unstructured Object渲染一个参数，自动识别label selector并过滤
 func RenderJsonPathParamForUnstructuredObj(obj *unstructured.Unstructured, paramDef *TemplateDynamicParam, paramPath *JsonPathParamTarget, value interface{}) error {
+	// 跳过不匹配目标选择器的对象
+	if matched, err := TargetMatchesObject(paramPath, obj); err != nil || !matched {
+		return err
+	}
+
+	// 处理JsonPatch类型参数
+	if paramDef.ParamType == ParamTypeJsonPatch {
+		return ApplyJsonPatchParam(obj, paramDef, value)
+	}
+
+	// 处理删除模式
+	if paramDef.InjectMode == InjectModeRemove {
+		return RemoveFieldOfUnstructuredObj(obj, paramPath.ParamJsonPath, value, paramDef.IgnoreMissing)
+	}
+
+	// 处理对象合并模式
+	if paramDef.InjectMode == InjectModeMerge {
+		return MergeFieldOfUnstructuredObj(obj, paramPath.ParamJsonPath, value)
+	}
+
+	// 处理策略合并模式
+	if paramDef.InjectMode == InjectModeStrategicMerge {
+		return StrategicMergeFieldOfUnstructuredObj(obj, paramPath.ParamJsonPath, value)
+	}
+
 	// 处理数组元素追加模式
 	if paramDef.AppendArray {
+		if paramDef.InsertPosition != nil {
+			return InsertArrayField(obj, paramPath.ParamJsonPath, value, paramDef.InsertPosition)
+		}
 		if err := AppendArrayField(obj, paramPath.ParamJsonPath, value); err != nil {
 			return err
 		}
@@ -79,6 +132,10 @@ func RenderJsonPathParamForUnstructuredObj(obj *unstructured.Unstructured, param
 // *将自动判断keyPath指定对象是否为数组类型，或为空时自动创建数组
 // *若keyPath位置的值不是数组类型，则抛出错误
 func AppendArrayField(obj *unstructured.Unstructured, keyPath string, value interface{}) error {
+	value, err := ToJSONValue(value)
+	if err != nil {
+		return err
+	}
 	return SetNestedField(obj.Object, keyPath, value, true)
 	// kp := parseKeyPath(keyPath)
 
@@ -110,6 +167,56 @@ func AppendArrayField(obj *unstructured.Unstructured, keyPath string, value inte
 	// return nil
 }
 
+// RemoveFieldOfUnstructuredObj 删除Unstructured对象中keyPath指定的字段或数组元素
+// value为false时不做删除，ignoreMissing为false时目标字段不存在将返回错误
+func RemoveFieldOfUnstructuredObj(obj *unstructured.Unstructured, keyPath string, value interface{}, ignoreMissing bool) error {
+	if enabled, ok := value.(bool); ok && !enabled {
+		return nil
+	}
+	removed, err := RemoveNestedField(obj.Object, keyPath)
+	if err != nil {
+		if ignoreMissing && errors.Cause(err) == ErrFieldNotExist {
+			return nil
+		}
+		return err
+	}
+	if removed < 1 && !ignoreMissing {
+		return errors.Wrap(ErrFieldNotExist, "nothing to remove: "+keyPath)
+	}
+	return nil
+}
+
+// InsertArrayField 在指定Unstructured对象的数组类型字段的指定位置插入值
+// *position可以是下标，或在匹配过滤表达式的元素之前/之后插入
+func InsertArrayField(obj *unstructured.Unstructured, keyPath string, value interface{}, position *ArrayInsertPosition) error {
+	value, err := ToJSONValue(value)
+	if err != nil {
+		return err
+	}
+	return InsertNestedField(obj.Object, keyPath, value, position)
+}
+
+// MergeFieldOfUnstructuredObj 将value按JSON merge patch(RFC 7386)语义深度合并到keyPath指定的字段，value中的null将删除对应的key
+func MergeFieldOfUnstructuredObj(obj *unstructured.Unstructured, keyPath string, value interface{}) error {
+	return MergeNestedField(obj.Object, keyPath, value)
+}
+
+// StrategicMergeFieldOfUnstructuredObj 将value按Kubernetes策略合并语义合并到keyPath指定的字段
+// containers、env、ports、volumes等已知列表按元素的key合并，不会产生重复元素
+func StrategicMergeFieldOfUnstructuredObj(obj *unstructured.Unstructured, keyPath string, value interface{}) error {
+	return StrategicMergeNestedField(obj.Object, keyPath, value)
+}
+
+// ApplyJsonPatchParam 将JsonPatch类型参数的JSON Patch文档绑定参数值后应用到Unstructured对象
+// patch中的test操作失败时返回错误且对象保持不变
+func ApplyJsonPatchParam(obj *unstructured.Unstructured, paramDef *TemplateDynamicParam, value interface{}) error {
+	patch, err := BindJsonPatchValue(paramDef.JsonPatch, paramDef.ParamCode, value)
+	if err != nil {
+		return err
+	}
+	return ApplyJsonPatch(obj.Object, patch)
+}
+
 // SetValueOfUnstructredObj 为指定的Unstructured对象在keyPath指定的位置上设置任意值
 // keyPath: `.spec.name1.name2` 格式的json path表达式
 // value: 需要设置的任意值
@@ -123,31 +230,21 @@ func SetValueOfUnstructredObj(obj *unstructured.Unstructured, jsonPathKey string
 func AppendMapForUnstructuredObj(obj *unstructured.Unstructured, mapParamJsonPathKey string, key string, value interface{}) error {
 	processFunc := func(targetValue interface{}) error {
 		// 完成参数设定
-		// 解析jsonPath表达式 e.g.  `.metadata.namespace` --> []string{"metadata", "namespace"}
-		// jsonPathArr := parseKeyPath(mapParamJsonPathKey)
-		// if len(key) > 0 {
-		// 	jsonPathArr = append(jsonPathArr, key)
-		// }
-		return SetNestedField(obj.Object, fmt.Sprintf("%s.%s", mapParamJsonPathKey, key), targetValue, false)
-	}
-
-	safeValue := reflect.ValueOf(value)
-	switch safeValue.Kind() {
-	case reflect.Slice:
-		genericSlice := make([]interface{}, safeValue.Len())
-		for i := 0; i < safeValue.Len(); i++ {
-			genericSlice[i] = safeValue.Index(i).Interface()
-		}
-		return processFunc(genericSlice)
-	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint8, reflect.Int, reflect.Int16, reflect.Int32, reflect.Int8:
-		intV, err := strconv.ParseInt(fmt.Sprintf("%d", value), 10, 64)
+		// 解析jsonPath表达式 e.g.  `.metadata.namespace`, key作为独立的一级追加到path末尾
+		path, err := compileJsonPathCached(mapParamJsonPathKey)
 		if err != nil {
-			return errors.Wrap(err, "整数型参数解析出错")
+			return err
+		}
+		if len(key) > 0 {
+			path = path.Child(key)
 		}
-		return processFunc(intV)
-	case reflect.Float32, reflect.Float64:
-		return processFunc(value.(float64))
-	default:
-		return processFunc(value)
+		return path.Set(obj.Object, targetValue, false)
+	}
+
+	// typed slices, maps and unsigned integers are converted to the json representation of unstructured objects
+	targetValue, err := ToJSONValue(value)
+	if err != nil {
+		return err
 	}
+	return processFunc(targetValue)
 }
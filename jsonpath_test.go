@@ -0,0 +1,359 @@
+package structemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCompileJsonPath(t *testing.T) {
+	cases := map[string]string{
+		"spec.parentRefs[0].name":                        ".spec.parentRefs[0].name",
+		"$.spec.parentRefs[0].name":                      ".spec.parentRefs[0].name",
+		".spec.parentRefs.[0].name":                      ".spec.parentRefs[0].name",
+		"metadata.annotations['app.kubernetes.io/name']": ".metadata.annotations['app.kubernetes.io/name']",
+		`metadata.labels["it's"]`:                        `.metadata.labels['it\'s']`,
+		`metadata.labels.app\.kubernetes\.io/name`:       ".metadata.labels['app.kubernetes.io/name']",
+		"$":                          ".",
+		"spec.containers[*].image":   ".spec.containers[*].image",
+		"spec.*.name":                ".spec[*].name",
+		"..resources.limits":         "..resources.limits",
+		`spec.c[?(@.name=="a]b")].x`: `.spec.c[?(@.name=="a]b")].x`,
+		"spec..['app.io/name']":      ".spec..['app.io/name']",
+	}
+	for expr, expected := range cases {
+		p, err := CompileJsonPath(expr)
+		if err != nil {
+			t.Logf("Failed compile %s: %+v", expr, err)
+			t.FailNow()
+			return
+		}
+		if p.String() != expected {
+			t.Logf("Unexpected normalized path of %s: %s", expr, p.String())
+			t.FailNow()
+			return
+		}
+	}
+
+	for _, expr := range []string{"spec..", "spec..[0]", "spec[abc]", "spec['name", "spec[0", "spec[-1]", "spec]", "spec[?(name)]", "spec[?(@.a[*]==1)]"} {
+		if _, err := CompileJsonPath(expr); err == nil {
+			t.Logf("Expected error of %s does not occurred", expr)
+			t.FailNow()
+			return
+		}
+	}
+}
+
+func TestJsonPathSetAnnotation(t *testing.T) {
+	obj := parseObject(t)
+	err := SetNestedField(obj, "metadata.annotations['app.kubernetes.io/name']", "tlsroute", false)
+	if err != nil {
+		t.Logf("Failed set annotation: %+v", err)
+		t.FailNow()
+		return
+	}
+	annotations := obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+	if annotations["app.kubernetes.io/name"] != "tlsroute" {
+		t.Logf("Unexpected annotations: %v", annotations)
+		t.FailNow()
+		return
+	}
+
+	v, err := GetValueOfNestedField(obj, "spec.rules[0].backendRefs[0].port")
+	if err != nil || v != int64(6443) && v != float64(6443) {
+		t.Logf("Unexpected value: %v, %+v", v, err)
+		t.FailNow()
+		return
+	}
+
+	if err := SetNestedField(obj, "spec.newList[1].name", "second", false); err != nil {
+		t.Logf("Failed auto create array: %+v", err)
+		t.FailNow()
+		return
+	}
+	if v, _ := GetValueOfNestedField(obj, "spec.newList[1].name"); v != "second" {
+		t.Logf("Unexpected value of auto created array: %v", v)
+		t.FailNow()
+		return
+	}
+}
+
+var workloadManifest string = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: workload
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox
+        resources:
+          limits:
+            cpu: 100m
+      containers:
+      - name: app
+        image: app:v1
+        resources:
+          limits:
+            cpu: "1"
+      - name: sidecar
+        image: sidecar:v1
+`
+
+func parseWorkload(t *testing.T) map[string]interface{} {
+	objs, err := LoadManifestsFromString(workloadManifest)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+		return nil
+	}
+	return objs.Objects[0].Object
+}
+
+func TestJsonPathWildcard(t *testing.T) {
+	obj := parseWorkload(t)
+	if err := SetNestedField(obj, "spec.template.spec.containers[*].imagePullPolicy", "Always", false); err != nil {
+		t.Logf("Failed set wildcard path: %+v", err)
+		t.FailNow()
+		return
+	}
+	policies, err := GetValueOfNestedField(obj, "spec.template.spec.containers[*].imagePullPolicy")
+	if err != nil {
+		t.Logf("Failed get wildcard path: %+v", err)
+		t.FailNow()
+		return
+	}
+	if arr, ok := policies.([]interface{}); !ok || len(arr) != 2 || arr[0] != "Always" || arr[1] != "Always" {
+		t.Logf("Unexpected values of wildcard path: %v", policies)
+		t.FailNow()
+		return
+	}
+
+	// wildcards never create nodes, matching nothing is an error
+	if err := SetNestedField(obj, "spec.missing[*].name", "x", false); errors.Cause(err) != ErrPathNotMatched {
+		t.Logf("Unexpected error: %+v", err)
+		t.FailNow()
+		return
+	}
+	if _, exists := obj["spec"].(map[string]interface{})["missing"]; exists {
+		t.Log("Wildcard path created a missing node")
+		t.FailNow()
+		return
+	}
+}
+
+func TestJsonPathRecursiveDescent(t *testing.T) {
+	obj := parseWorkload(t)
+	if err := SetNestedField(obj, "..resources.limits.memory", "1Gi", false); err != nil {
+		t.Logf("Failed set recursive path: %+v", err)
+		t.FailNow()
+		return
+	}
+	memory, err := GetValueOfNestedField(obj, "..limits.memory")
+	if err != nil {
+		t.Logf("Failed get recursive path: %+v", err)
+		t.FailNow()
+		return
+	}
+	if arr, ok := memory.([]interface{}); !ok || len(arr) != 2 {
+		t.Logf("Unexpected values of recursive path: %v", memory)
+		t.FailNow()
+		return
+	}
+	images, _ := GetValueOfNestedField(obj, "spec..image")
+	if arr, ok := images.([]interface{}); !ok || len(arr) != 3 {
+		t.Logf("Unexpected images: %v", images)
+		t.FailNow()
+		return
+	}
+}
+
+func TestJsonPathFilter(t *testing.T) {
+	obj := parseWorkload(t)
+	if err := SetNestedField(obj, `spec.template.spec.containers[?(@.name=="sidecar")].image`, "sidecar:v2", false); err != nil {
+		t.Logf("Failed set filtered path: %+v", err)
+		t.FailNow()
+		return
+	}
+	if err := SetNestedField(obj, "spec.template.spec.containers[name=app].image", "app:v2", false); err != nil {
+		t.Logf("Failed set shorthand filtered path: %+v", err)
+		t.FailNow()
+		return
+	}
+	images, _ := GetValueOfNestedField(obj, "spec.template.spec.containers[*].image")
+	if arr := images.([]interface{}); arr[0] != "app:v2" || arr[1] != "sidecar:v2" {
+		t.Logf("Unexpected images: %v", images)
+		t.FailNow()
+		return
+	}
+
+	cpu, _ := GetValueOfNestedField(obj, `..containers[?(@.resources.limits.cpu == '1')].name`)
+	if arr := cpu.([]interface{}); len(arr) != 1 || arr[0] != "app" {
+		t.Logf("Unexpected filtered names: %v", cpu)
+		t.FailNow()
+		return
+	}
+	withResources, _ := GetValueOfNestedField(obj, "spec.template.spec.containers[?(@.resources)].name")
+	if arr := withResources.([]interface{}); len(arr) != 1 {
+		t.Logf("Unexpected existence filter result: %v", withResources)
+		t.FailNow()
+		return
+	}
+
+	hostObj := parseObject(t)
+	hostnames, _ := GetValueOfNestedField(hostObj, `spec.hostnames[?(@ != "hostname1.example.com")]`)
+	if arr := hostnames.([]interface{}); len(arr) != 1 || arr[0] != "hostname2.example.com" {
+		t.Logf("Unexpected filtered hostnames: %v", hostnames)
+		t.FailNow()
+		return
+	}
+	ports, _ := GetValueOfNestedField(hostObj, "spec.parentRefs[port=20022].name")
+	if arr := ports.([]interface{}); len(arr) != 1 {
+		t.Logf("Unexpected shorthand filter result of number field: %v", ports)
+		t.FailNow()
+		return
+	}
+	others, _ := GetValueOfNestedField(obj, "spec.template.spec.containers[name!=app].name")
+	if arr := others.([]interface{}); len(arr) != 1 || arr[0] != "sidecar" {
+		t.Logf("Unexpected shorthand != filter result: %v", others)
+		t.FailNow()
+		return
+	}
+	for _, expr := range []string{"spec.containers[port>=80]", "spec.containers[name~=app]", "spec.containers[?(@.port>1)]",
+		"spec.containers[?(@.port <= 1)]", `spec.containers[?(@.name=~"a")]`, "spec.containers[?(@.name!)]", "spec.containers[?(@.name='a')]"} {
+		if _, err := CompileJsonPath(expr); err == nil {
+			t.Logf("Unsupported filter operator is accepted: %s", expr)
+			t.FailNow()
+			return
+		}
+	}
+
+	// a filter matching nothing fails to set a value
+	if err := SetNestedField(obj, "spec.template.spec.containers[name=missing].image", "x", false); errors.Cause(err) != ErrPathNotMatched {
+		t.Logf("Unexpected error: %+v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestJsonPathInsert(t *testing.T) {
+	obj := parseWorkload(t)
+	containersPath := "spec.template.spec.containers"
+	names := func() string {
+		v, _ := GetValueOfNestedField(obj, containersPath+"[*].name")
+		result := ""
+		for _, name := range v.([]interface{}) {
+			result += name.(string) + ","
+		}
+		return result
+	}
+
+	if err := InsertNestedField(obj, containersPath, map[string]interface{}{"name": "first"}, PrependPosition()); err != nil {
+		t.Logf("Failed prepend: %+v", err)
+		t.FailNow()
+		return
+	}
+	if err := InsertNestedField(obj, containersPath, map[string]interface{}{"name": "before-sidecar"}, &ArrayInsertPosition{Before: "name=sidecar"}); err != nil {
+		t.Logf("Failed insert before: %+v", err)
+		t.FailNow()
+		return
+	}
+	after := []interface{}{map[string]interface{}{"name": "after-app1"}, map[string]interface{}{"name": "after-app2"}}
+	if err := InsertNestedField(obj, containersPath, after, &ArrayInsertPosition{After: `[?(@.name=="app")]`}); err != nil {
+		t.Logf("Failed insert after: %+v", err)
+		t.FailNow()
+		return
+	}
+	idx := 1
+	if err := InsertNestedField(obj, containersPath, map[string]interface{}{"name": "second"}, &ArrayInsertPosition{Index: &idx}); err != nil {
+		t.Logf("Failed insert at index: %+v", err)
+		t.FailNow()
+		return
+	}
+	if names() != "first,second,app,after-app1,after-app2,before-sidecar,sidecar," {
+		t.Logf("Unexpected containers order: %s", names())
+		t.FailNow()
+		return
+	}
+
+	idx = 100
+	if err := InsertNestedField(obj, containersPath, "x", &ArrayInsertPosition{Index: &idx}); err == nil {
+		t.Log("Expected error of index out of bounds does not occurred")
+		t.FailNow()
+		return
+	}
+	if err := InsertNestedField(obj, containersPath, "x", &ArrayInsertPosition{Before: "name=missing"}); err == nil {
+		t.Log("Expected error of missing element does not occurred")
+		t.FailNow()
+		return
+	}
+}
+
+func TestJsonPathCache(t *testing.T) {
+	cache := newLRUCache[*JsonPath](2)
+	for _, expr := range []string{"a", "b", "a", "c"} {
+		if _, ok := cache.get(expr); !ok {
+			cache.add(expr, MustCompileJsonPath(expr))
+		}
+	}
+	// "b" is the least recently used one
+	if _, ok := cache.get("b"); ok || cache.len() != 2 {
+		t.Logf("Unexpected cache size: %d", cache.len())
+		t.FailNow()
+		return
+	}
+	if p, ok := cache.get("a"); !ok || p.String() != ".a" {
+		t.Logf("Recently used path is dropped")
+		t.FailNow()
+		return
+	}
+
+	for i := 0; i < maxCachedJsonPaths+10; i++ {
+		if _, err := compileJsonPathCached(fmt.Sprintf("spec.items[%d]", i)); err != nil {
+			t.Logf("Failed compile json path: %v", err)
+			t.FailNow()
+			return
+		}
+	}
+	if compiledJsonPaths.len() > maxCachedJsonPaths {
+		t.Logf("Cache is not bounded: %d", compiledJsonPaths.len())
+		t.FailNow()
+		return
+	}
+}
+
+func TestJsonPathSetTypedValues(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"ports": []interface{}{int64(80)}}}}
+	params := []struct {
+		def   TemplateDynamicParam
+		path  string
+		value interface{}
+	}{
+		{TemplateDynamicParam{ParamCode: "replicas"}, "spec.replicas", uint64(3)},
+		{TemplateDynamicParam{ParamCode: "sizes"}, "spec.sizes", []int{1, 2}},
+		{TemplateDynamicParam{ParamCode: "limits", MapKey: "limits"}, "spec.resources", map[string]int{"cpu": 2}},
+		{TemplateDynamicParam{ParamCode: "ports", AppendArray: true}, "spec.ports", uint16(443)},
+		{TemplateDynamicParam{ParamCode: "extra", AppendArray: true}, "spec.extra", map[string]uint64{"a": 1}},
+	}
+	for _, p := range params {
+		def := p.def
+		if err := RenderJsonPathParamForUnstructuredObj(obj, &def, &JsonPathParamTarget{ParamJsonPath: p.path}, p.value); err != nil {
+			t.Logf("Failed set %s: %+v", p.def.ParamCode, err)
+			t.FailNow()
+			return
+		}
+	}
+	// typed values must not break the deep copy of unstructured objects
+	copied := obj.DeepCopy()
+	result, _ := json.Marshal(copied.Object)
+	if string(result) != `{"spec":{"extra":[{"a":1}],"ports":[80,443],"replicas":3,"resources":{"limits":{"cpu":2}},"sizes":[1,2]}}` {
+		t.Logf("Unexpected result: %s", result)
+		t.FailNow()
+		return
+	}
+}
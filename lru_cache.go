@@ -0,0 +1,57 @@
+package structemplate
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a LRU cache safe for concurrent use, used to bound the caches of values compiled
+// from template definitions like json paths and constraint patterns.
+type lruCache[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // most recently used first, values are *lruCacheEntry
+	entries  map[string]*list.Element
+}
+
+type lruCacheEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRUCache[V any](capacity int) *lruCache[V] {
+	return &lruCache[V]{capacity: capacity, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *lruCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruCacheEntry[V]).value, true
+}
+
+func (c *lruCache[V]) add(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruCacheEntry[V]{key: key, value: value})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruCacheEntry[V]).key)
+	}
+}
+
+func (c *lruCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,113 @@
+package structemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// ManifestObjects holds the objects decoded from a multi-document manifest.
+// Objects keeps the original document order while ObjectsMap groups the same objects by GVK
+// and can be passed to RenderJsonPathParams directly.
+type ManifestObjects struct {
+	Objects    []*unstructured.Unstructured
+	ObjectsMap map[schema.GroupVersionKind][]*unstructured.Unstructured
+}
+
+// LoadManifests reads a yaml/json stream containing one or more documents.
+// Documents are separated by `---` in yaml, empty documents and comments are ignored,
+// items of `List` kinds (e.g. v1/List, ConfigMapList) are flattened into separate objects.
+func LoadManifests(r io.Reader) (*ManifestObjects, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	result := &ManifestObjects{
+		Objects:    make([]*unstructured.Unstructured, 0),
+		ObjectsMap: make(map[schema.GroupVersionKind][]*unstructured.Unstructured),
+	}
+
+	for docIdx := 0; ; docIdx++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err, fmt.Sprintf("cannot decode document %d", docIdx))
+		}
+
+		if len(bytes.TrimSpace(raw)) == 0 || string(raw) == "null" {
+			// empty document or document with comments only
+			continue
+		}
+
+		obj := make(map[string]interface{})
+		// util/json keeps integers as int64, which the unstructured helpers expect
+		if err := utiljson.Unmarshal(raw, &obj); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("document %d is not an object", docIdx))
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		if err := result.add(&unstructured.Unstructured{Object: obj}); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("cannot load document %d", docIdx))
+		}
+	}
+	return result, nil
+}
+
+// LoadManifestsFromString is LoadManifests for a manifest string.
+func LoadManifestsFromString(manifest string) (*ManifestObjects, error) {
+	return LoadManifests(strings.NewReader(manifest))
+}
+
+func (m *ManifestObjects) add(obj *unstructured.Unstructured) error {
+	if strings.HasSuffix(obj.GetKind(), "List") && obj.IsList() {
+		items, _, err := unstructured.NestedSlice(obj.Object, "items")
+		if err != nil {
+			return err
+		}
+		for i, item := range items {
+			itemObj, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("item %d of %s is not an object", i, obj.GetKind())
+			}
+			if err := m.add(&unstructured.Unstructured{Object: itemObj}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	gvk := obj.GroupVersionKind()
+	m.Objects = append(m.Objects, obj)
+	m.ObjectsMap[gvk] = append(m.ObjectsMap[gvk], obj)
+	return nil
+}
+
+// ToYAML serializes the objects to a multi-document yaml stream in their original order.
+func (m *ManifestObjects) ToYAML() ([]byte, error) {
+	return ObjectsToYAML(m.Objects)
+}
+
+// ObjectsToYAML serializes objects to a multi-document yaml stream separated by `---`.
+func ObjectsToYAML(objs []*unstructured.Unstructured) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for i, obj := range objs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		docB, err := sigsyaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("cannot serialize object %d", i))
+		}
+		buf.Write(docB)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package structemplate
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var multiDocManifest string = `
+# leading comment
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc-a
+spec:
+  ports:
+  - port: 80
+---
+# empty document
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm-a
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: svc-b
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: deploy-a
+`
+
+func TestLoadManifests(t *testing.T) {
+	objs, err := LoadManifestsFromString(multiDocManifest)
+	if err != nil {
+		t.Logf("Failed load manifests: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	names := make([]string, 0)
+	for _, obj := range objs.Objects {
+		names = append(names, obj.GetName())
+	}
+	if strings.Join(names, ",") != "svc-a,cm-a,svc-b,deploy-a" {
+		t.Logf("Unexpected objects order: %v", names)
+		t.FailNow()
+		return
+	}
+
+	services := objs.ObjectsMap[schema.GroupVersionKind{Version: "v1", Kind: "Service"}]
+	if len(services) != 2 || services[1].GetName() != "svc-b" {
+		t.Logf("Unexpected Service objects: %d", len(services))
+		t.FailNow()
+		return
+	}
+
+	port, err := GetValueOfNestedField(services[0].Object, ".spec.ports.[0].port")
+	if err != nil || port != int64(80) {
+		t.Logf("Integer field is not decoded as int64: %T", port)
+		t.FailNow()
+		return
+	}
+
+	out, err := objs.ToYAML()
+	if err != nil {
+		t.Logf("Failed serialize objects: %+v", err)
+		t.FailNow()
+		return
+	}
+	reloaded, err := LoadManifests(strings.NewReader(string(out)))
+	if err != nil || len(reloaded.Objects) != 4 || reloaded.Objects[3].GetName() != "deploy-a" {
+		t.Logf("Serialized stream cannot be reloaded: %s", out)
+		t.FailNow()
+		return
+	}
+}
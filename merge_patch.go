@@ -0,0 +1,114 @@
+package structemplate
+
+import (
+	"math"
+	"reflect"
+
+	"github.com/pkg/errors"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+)
+
+// MergePatch applies a JSON merge patch (RFC 7386) to target and returns the result.
+// Objects are merged recursively, a null value in patch deletes the key, any other value replaces the target.
+// target is modified in place when it is an object, patch is never modified.
+func MergePatch(target interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return DeepCopyJSONValue(patch)
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{}, len(patchObj))
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = MergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
+
+// Merge deep merges patch into every node located by the path following JSON merge patch (RFC 7386) semantics.
+// Missing parent fields are created, a missing node is treated as an empty object.
+// An error caused by ErrPathNotMatched is returned when a multi-match path matches nothing.
+func (p *JsonPath) Merge(object map[string]interface{}, patch interface{}) error {
+	patch, err := ToJSONValue(patch)
+	if err != nil {
+		return err
+	}
+	if p.IsRoot() {
+		patchObj, ok := patch.(map[string]interface{})
+		if !ok {
+			return errors.New("only an object can be merged into the root object")
+		}
+		MergePatch(object, patchObj)
+		return nil
+	}
+
+	refs, err := p.resolveTargets(object)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		current, _ := ref.get()
+		ref.set(MergePatch(current, patch))
+	}
+	return nil
+}
+
+// ToJSONValue converts typed maps, slices and structs to the generic json representation
+// (map[string]interface{}, []interface{}, int64, float64, string, bool, nil) used by unstructured objects.
+// Unsigned integers above math.MaxInt64 are converted to float64. The passed value is not modified.
+func ToJSONValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, string, bool, int64, float64:
+		return v, nil
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			converted, err := ToJSONValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = converted
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, elem := range v {
+			converted, err := ToJSONValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = converted
+		}
+		return result, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			// like the json decoding of apimachinery, integers overflowing int64 become float64
+			return float64(rv.Uint()), nil
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32:
+		return rv.Float(), nil
+	}
+
+	// typed maps, slices and structs
+	b, err := utiljson.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "value cannot be converted to json")
+	}
+	var result interface{}
+	if err := utiljson.Unmarshal(b, &result); err != nil {
+		return nil, errors.Wrap(err, "value cannot be converted to json")
+	}
+	return result, nil
+}
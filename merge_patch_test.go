@@ -0,0 +1,87 @@
+package structemplate
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	target := map[string]interface{}{
+		"a": "b",
+		"c": map[string]interface{}{"d": "e", "f": "g"},
+		"h": []interface{}{"i"},
+	}
+	patch := map[string]interface{}{
+		"a": "z",
+		"c": map[string]interface{}{"f": nil, "x": int64(1)},
+		"h": []interface{}{"j"},
+		"k": nil,
+	}
+	expected := map[string]interface{}{
+		"a": "z",
+		"c": map[string]interface{}{"d": "e", "x": int64(1)},
+		"h": []interface{}{"j"},
+	}
+	if result := MergePatch(target, patch); !jsonValueEqual(result, expected) {
+		t.Logf("Unexpected merge result: %v", result)
+		t.FailNow()
+		return
+	}
+}
+
+func TestToJSONValue_Uint64(t *testing.T) {
+	cases := map[interface{}]interface{}{
+		uint64(math.MaxInt64):     int64(math.MaxInt64),
+		uint64(math.MaxInt64) + 1: float64(1 << 63),
+		uint8(7):                  int64(7),
+	}
+	for value, expected := range cases {
+		if converted, err := ToJSONValue(value); err != nil || converted != expected {
+			t.Logf("Unexpected conversion of %v: %#v, %v", value, converted, err)
+			t.FailNow()
+			return
+		}
+	}
+}
+
+func TestRenderJsonpathParam_Merge(t *testing.T) {
+	obj := parseWorkload(t)
+	err := MergeNestedField(obj, "spec.template.spec.containers[name=app].resources", map[string]interface{}{
+		"limits":   map[string]string{"memory": "1Gi"},
+		"requests": map[string]interface{}{"cpu": "500m"},
+	})
+	if err != nil {
+		t.Logf("Failed merge field: %+v", err)
+		t.FailNow()
+		return
+	}
+	limits, _ := GetValueOfNestedField(obj, "spec.template.spec.containers[0].resources.limits")
+	if !jsonValueEqual(limits, map[string]interface{}{"cpu": "1", "memory": "1Gi"}) {
+		t.Logf("Unexpected limits after merge: %v", limits)
+		t.FailNow()
+		return
+	}
+
+	// null deletes the key, missing nodes are created
+	err = MergeNestedField(obj, "spec.template.spec.containers[0].resources", map[string]interface{}{"limits": nil})
+	if err != nil {
+		t.Logf("Failed merge field: %+v", err)
+		t.FailNow()
+		return
+	}
+	if v, _ := GetValueOfNestedField(obj, "spec.template.spec.containers[0].resources.limits"); v != nil {
+		t.Logf("Key is not deleted by null: %v", v)
+		t.FailNow()
+		return
+	}
+	if err := MergeNestedField(obj, "spec.template.metadata.labels", map[string]interface{}{"app": "demo"}); err != nil {
+		t.Logf("Failed merge into missing field: %+v", err)
+		t.FailNow()
+		return
+	}
+	if v, _ := GetValueOfNestedField(obj, "spec.template.metadata.labels.app"); v != "demo" {
+		t.Logf("Unexpected merged label: %v", v)
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,86 @@
+package structemplate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SelectTargetObjects selects the objects matching the selectors of an inject target.
+// A target without any selector matches every object passed in.
+func SelectTargetObjects(objs []*unstructured.Unstructured, target *JsonPathParamTarget) ([]*unstructured.Unstructured, error) {
+	selected := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		matched, err := TargetMatchesObject(target, obj)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			selected = append(selected, obj)
+		}
+	}
+	return selected, nil
+}
+
+// TargetMatchesObject checks whether an object matches all the selectors of an inject target.
+// An error is returned when the label expressions of the target are invalid.
+func TargetMatchesObject(target *JsonPathParamTarget, obj *unstructured.Unstructured) (bool, error) {
+	if len(target.ObjectName) > 0 && obj.GetName() != target.ObjectName {
+		return false, nil
+	}
+	if len(target.ObjectNamespace) > 0 && obj.GetNamespace() != target.ObjectNamespace {
+		return false, nil
+	}
+	if len(target.ObjectAnnotationSelector) > 0 {
+		objAnnotations := obj.GetAnnotations()
+		for k, v := range target.ObjectAnnotationSelector {
+			if av, ok := objAnnotations[k]; !ok || av != v {
+				return false, nil
+			}
+		}
+	}
+
+	selector, err := target.LabelSelector()
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(obj.GetLabels())), nil
+}
+
+// LabelSelector builds a labels.Selector from ObjectLabelSelector and ObjectLabelExpressions.
+// An empty target matches everything.
+func (t *JsonPathParamTarget) LabelSelector() (labels.Selector, error) {
+	if len(t.ObjectLabelSelector) < 1 && len(t.ObjectLabelExpressions) < 1 {
+		return labels.Everything(), nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
+		MatchLabels:      t.ObjectLabelSelector,
+		MatchExpressions: t.ObjectLabelExpressions,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid label selector of inject target")
+	}
+	return selector, nil
+}
+
+// String describes the target object selectors, used in error messages.
+func (t *JsonPathParamTarget) String() string {
+	conds := []string{t.TargetGVK.String()}
+	if len(t.ObjectNamespace) > 0 {
+		conds = append(conds, "namespace="+t.ObjectNamespace)
+	}
+	if len(t.ObjectName) > 0 {
+		conds = append(conds, "name="+t.ObjectName)
+	}
+	if selector, err := t.LabelSelector(); err == nil && !selector.Empty() {
+		conds = append(conds, "labels="+selector.String())
+	}
+	if len(t.ObjectAnnotationSelector) > 0 {
+		conds = append(conds, fmt.Sprintf("annotations=%v", t.ObjectAnnotationSelector))
+	}
+	return strings.Join(conds, " ")
+}
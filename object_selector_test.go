@@ -0,0 +1,101 @@
+package structemplate
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSelectTargetObjects(t *testing.T) {
+	newObj := func(namespace string, name string, objLabels map[string]string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service"}}
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		obj.SetLabels(objLabels)
+		return obj
+	}
+	objs := []*unstructured.Unstructured{
+		newObj("default", "web", map[string]string{"tier": "frontend"}),
+		newObj("default", "api", map[string]string{"tier": "backend", "canary": "true"}),
+		newObj("system", "api", nil),
+	}
+	objs[0].SetAnnotations(map[string]string{"team": "web", "app.kubernetes.io/part-of": "shop"})
+	objs[1].SetAnnotations(map[string]string{"team": "api", "app.kubernetes.io/part-of": "shop"})
+
+	cases := []struct {
+		name     string
+		target   JsonPathParamTarget
+		expected int
+	}{
+		{"no selector", JsonPathParamTarget{}, 3},
+		{"by name", JsonPathParamTarget{ObjectName: "api"}, 2},
+		{"by name and namespace", JsonPathParamTarget{ObjectName: "api", ObjectNamespace: "system"}, 1},
+		{"label in", JsonPathParamTarget{ObjectLabelExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"frontend", "backend"}},
+		}}, 2},
+		{"label does not exist", JsonPathParamTarget{ObjectLabelExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "canary", Operator: metav1.LabelSelectorOpDoesNotExist},
+		}}, 2},
+		{"label map and expression", JsonPathParamTarget{
+			ObjectLabelSelector: map[string]string{"tier": "backend"},
+			ObjectLabelExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "canary", Operator: metav1.LabelSelectorOpExists},
+			},
+		}, 1},
+		{"annotation", JsonPathParamTarget{ObjectAnnotationSelector: map[string]string{"app.kubernetes.io/part-of": "shop"}}, 2},
+		{"annotations and labels", JsonPathParamTarget{
+			ObjectAnnotationSelector: map[string]string{"team": "api"},
+			ObjectLabelSelector:      map[string]string{"tier": "backend"},
+		}, 1},
+		{"annotation value mismatch", JsonPathParamTarget{ObjectAnnotationSelector: map[string]string{"team": "ops"}}, 0},
+		{"annotation missing", JsonPathParamTarget{ObjectAnnotationSelector: map[string]string{"owner": "web"}}, 0},
+	}
+	for _, c := range cases {
+		selected, err := SelectTargetObjects(objs, &c.target)
+		if err != nil {
+			t.Logf("%s: failed select objects: %+v", c.name, err)
+			t.FailNow()
+			return
+		}
+		if len(selected) != c.expected {
+			t.Logf("%s: expected %d objects, got %d", c.name, c.expected, len(selected))
+			t.FailNow()
+			return
+		}
+	}
+
+	invalid := JsonPathParamTarget{ObjectLabelExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "tier", Operator: metav1.LabelSelectorOpIn},
+	}}
+	if _, err := SelectTargetObjects(objs, &invalid); err == nil {
+		t.Log("Expected error of invalid expression does not occurred")
+		t.FailNow()
+		return
+	}
+}
+
+func TestTargetMatchesObject_Annotations(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}}
+	obj.SetName("config")
+	obj.SetAnnotations(map[string]string{"team": "web"})
+
+	target := &JsonPathParamTarget{ObjectName: "config", ObjectAnnotationSelector: map[string]string{"team": "web"}}
+	if matched, err := TargetMatchesObject(target, obj); err != nil || !matched {
+		t.Logf("Object with matching annotations is not matched: %v", err)
+		t.FailNow()
+		return
+	}
+	target.ObjectAnnotationSelector["team"] = "api"
+	if matched, err := TargetMatchesObject(target, obj); err != nil || matched {
+		t.Logf("Object with another annotation value is matched: %v", err)
+		t.FailNow()
+		return
+	}
+	obj.SetAnnotations(nil)
+	if matched, err := TargetMatchesObject(target, obj); err != nil || matched {
+		t.Logf("Object without annotations is matched: %v", err)
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,321 @@
+package structemplate
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// ActiveParams returns the params whose VisibleIf condition holds for the values, in their original order.
+// Hidden params are skipped by the renderers and the validation: their values are ignored and their defaults are not injected.
+// A condition referencing a hidden param sees no value, and params with cyclic visibility conditions are rejected.
+// The error is a ParamValueErrors of InvalidCondition errors, the params involved are left out of the result.
+func ActiveParams(params []TemplateDynamicParam, values ParamValuesMap) ([]TemplateDynamicParam, error) {
+	visible, err := resolveParamVisibility(params, values)
+	active := make([]TemplateDynamicParam, 0, len(params))
+	for i := range params {
+		if visible[i] {
+			active = append(active, params[i])
+		}
+	}
+	return active, err
+}
+
+// resolveParamVisibility resolves the VisibleIf conditions of params, the result is indexed like params.
+// Params with invalid conditions are left out of the result and reported as in ActiveParams.
+func resolveParamVisibility(params []TemplateDynamicParam, values ParamValuesMap) (map[int]bool, error) {
+	e := newParamConditionEvaluator(params, values)
+	errs := make(ParamValueErrors, 0)
+	for i := range params {
+		if _, err := e.isVisible(i); err != nil {
+			errs = appendConditionError(errs, err.(*ParamValueError))
+		}
+	}
+	if len(errs) > 0 {
+		return e.visible, errs
+	}
+	return e.visible, nil
+}
+
+// CheckParamConditions checks the RequiredIf, DependsOn and ConflictsWith conditions of the visible params:
+//   - a param whose RequiredIf condition holds must have a value, provided or default
+//   - a param provided with a value requires every param of DependsOn to have a value
+//   - a param provided with a value conflicts with the params of ConflictsWith provided with a value
+//
+// The error is a ParamValueErrors listing every violated condition.
+func CheckParamConditions(params []TemplateDynamicParam, values ParamValuesMap) error {
+	return checkParamConditions(params, values, nil)
+}
+
+// checkParamConditions checks the conditions with the visibility of params resolved before, see resolveParamVisibility,
+// so values added afterwards such as generated values do not change which params are visible.
+// The visibility is resolved with values when visible is nil.
+func checkParamConditions(params []TemplateDynamicParam, values ParamValuesMap, visible map[int]bool) error {
+	e := newParamConditionEvaluator(params, values)
+	for i, v := range visible {
+		e.visible[i] = v
+	}
+	errs := make(ParamValueErrors, 0)
+	conflicts := make(map[string]bool)
+	for i := range params {
+		param := &params[i]
+		visible, err := e.isVisible(i)
+		if err != nil {
+			errs = appendConditionError(errs, err.(*ParamValueError))
+			continue
+		}
+		if !visible {
+			continue
+		}
+
+		if param.RequiredIf != nil {
+			required, err := e.eval(param.RequiredIf)
+			if err != nil {
+				errs = appendConditionError(errs, err.(*ParamValueError))
+				continue
+			}
+			if required && !hasParamValue(e.value(param.ParamCode)) {
+				errs = append(errs, &ParamValueError{Code: ErrCodeRequiredIf, ParamCode: param.ParamCode, Reason: param.RequiredIf.String()})
+			}
+		}
+
+		if !e.provided(param.ParamCode) {
+			continue
+		}
+		for _, dep := range param.DependsOn {
+			if !hasParamValue(e.value(dep)) {
+				errs = append(errs, &ParamValueError{Code: ErrCodeMissingDependency, ParamCode: param.ParamCode, Limit: dep})
+			}
+		}
+		for _, other := range param.ConflictsWith {
+			pair := []string{param.ParamCode, other}
+			sort.Strings(pair)
+			key := strings.Join(pair, "\n")
+			if e.provided(other) && !conflicts[key] {
+				conflicts[key] = true
+				errs = append(errs, &ParamValueError{Code: ErrCodeConflict, ParamCode: param.ParamCode, Limit: other})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// appendConditionError appends an error, an InvalidCondition error is skipped when the param already has one.
+func appendConditionError(errs ParamValueErrors, err *ParamValueError) ParamValueErrors {
+	if err.Code != ErrCodeInvalidCondition {
+		return append(errs, err)
+	}
+	for _, e := range errs {
+		if e.Code == err.Code && e.ParamCode == err.ParamCode {
+			return errs
+		}
+	}
+	return append(errs, err)
+}
+
+// hasParamValue reports whether a value is set, nil and empty strings are no value.
+func hasParamValue(value interface{}) bool {
+	if s, ok := value.(string); ok {
+		return s != ""
+	}
+	return value != nil
+}
+
+// paramConditionEvaluator evaluates the conditions of params, the visibility of every param is resolved once.
+type paramConditionEvaluator struct {
+	params   []TemplateDynamicParam
+	values   ParamValuesMap
+	defs     map[string][]int // indexes of the definitions of every param code
+	visible  map[int]bool
+	visiting map[int]bool
+}
+
+func newParamConditionEvaluator(params []TemplateDynamicParam, values ParamValuesMap) *paramConditionEvaluator {
+	e := &paramConditionEvaluator{
+		params:   params,
+		values:   values,
+		defs:     make(map[string][]int),
+		visible:  make(map[int]bool),
+		visiting: make(map[int]bool),
+	}
+	for i := range params {
+		e.defs[params[i].ParamCode] = append(e.defs[params[i].ParamCode], i)
+	}
+	return e
+}
+
+func (e *paramConditionEvaluator) isVisible(i int) (bool, error) {
+	if visible, ok := e.visible[i]; ok {
+		return visible, nil
+	}
+	param := &e.params[i]
+	if param.VisibleIf == nil {
+		e.visible[i] = true
+		return true, nil
+	}
+	if e.visiting[i] {
+		return false, &ParamValueError{Code: ErrCodeInvalidCondition, ParamCode: param.ParamCode, Reason: "cyclic visibility conditions"}
+	}
+	e.visiting[i] = true
+	visible, err := e.eval(param.VisibleIf)
+	delete(e.visiting, i)
+	if err != nil {
+		return false, err
+	}
+	e.visible[i] = visible
+	return visible, nil
+}
+
+// visibleDefs returns the visible definitions of a param code, nil when the code is not defined.
+// Errors of cyclic conditions are raised by eval, the definitions failing here are treated as hidden.
+func (e *paramConditionEvaluator) visibleDefs(code string) []*TemplateDynamicParam {
+	defs := make([]*TemplateDynamicParam, 0)
+	for _, i := range e.defs[code] {
+		if visible, err := e.isVisible(i); err == nil && visible {
+			defs = append(defs, &e.params[i])
+		}
+	}
+	return defs
+}
+
+// provided reports whether a value is provided for a param code which is not hidden.
+func (e *paramConditionEvaluator) provided(code string) bool {
+	if _, defined := e.defs[code]; defined && len(e.visibleDefs(code)) < 1 {
+		return false
+	}
+	return hasParamValue(e.values[code])
+}
+
+// value returns the provided value or the default of a param code, coerced to the ValueDataType when possible.
+// Hidden params have no value, values of undefined codes are returned as they are.
+func (e *paramConditionEvaluator) value(code string) interface{} {
+	value, exist := e.values[code]
+	if _, defined := e.defs[code]; !defined {
+		return value
+	}
+	defs := e.visibleDefs(code)
+	if len(defs) < 1 {
+		return nil
+	}
+	for _, def := range defs {
+		if !exist && def.Default != nil {
+			value = def.Default
+			break
+		}
+	}
+	for _, def := range defs {
+		if def.ValueDataType != "" {
+			if coerced, err := CoerceParamValue(def, value); err == nil {
+				return coerced
+			}
+			break
+		}
+	}
+	return value
+}
+
+func (e *paramConditionEvaluator) eval(c *ParamCondition) (bool, error) {
+	if c.Param != "" {
+		// a cycle through the referenced param is reported before its value is looked up
+		for _, i := range e.defs[c.Param] {
+			if _, err := e.isVisible(i); err != nil {
+				return false, err
+			}
+		}
+		value := e.value(c.Param)
+		if c.Equals != nil && !jsonValueEqual(normalizeJSONValue(value), normalizeJSONValue(c.Equals)) {
+			return false, nil
+		}
+		if c.NotEquals != nil && jsonValueEqual(normalizeJSONValue(value), normalizeJSONValue(c.NotEquals)) {
+			return false, nil
+		}
+		if len(c.In) > 0 && !containsJSONValue(c.In, value) {
+			return false, nil
+		}
+		if c.Exists != nil && hasParamValue(value) != *c.Exists {
+			return false, nil
+		}
+		if c.Equals == nil && c.NotEquals == nil && len(c.In) < 1 && c.Exists == nil && !hasParamValue(value) {
+			return false, nil
+		}
+	}
+	for i := range c.AllOf {
+		if ok, err := e.eval(&c.AllOf[i]); err != nil || !ok {
+			return false, err
+		}
+	}
+	if len(c.AnyOf) > 0 {
+		matched := false
+		for i := range c.AnyOf {
+			ok, err := e.eval(&c.AnyOf[i])
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if c.Not != nil {
+		ok, err := e.eval(c.Not)
+		if err != nil || ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// String returns a readable form of the condition like `EXPOSE == "ingress" && TLS`.
+func (c *ParamCondition) String() string {
+	parts := make([]string, 0)
+	if c.Param != "" {
+		if c.Equals != nil {
+			parts = append(parts, c.Param+" == "+conditionValueString(c.Equals))
+		}
+		if c.NotEquals != nil {
+			parts = append(parts, c.Param+" != "+conditionValueString(c.NotEquals))
+		}
+		if len(c.In) > 0 {
+			parts = append(parts, c.Param+" in "+conditionValueString(c.In))
+		}
+		if c.Exists != nil && !*c.Exists {
+			parts = append(parts, "!"+c.Param)
+		}
+		if len(parts) < 1 {
+			parts = append(parts, c.Param)
+		}
+	}
+	if len(c.AllOf) > 0 {
+		all := make([]string, 0, len(c.AllOf))
+		for i := range c.AllOf {
+			all = append(all, c.AllOf[i].String())
+		}
+		parts = append(parts, "("+strings.Join(all, " && ")+")")
+	}
+	if len(c.AnyOf) > 0 {
+		oneOf := make([]string, 0, len(c.AnyOf))
+		for i := range c.AnyOf {
+			oneOf = append(oneOf, c.AnyOf[i].String())
+		}
+		parts = append(parts, "("+strings.Join(oneOf, " || ")+")")
+	}
+	if c.Not != nil {
+		parts = append(parts, "!("+c.Not.String()+")")
+	}
+	return strings.Join(parts, " && ")
+}
+
+func conditionValueString(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "<invalid>"
+	}
+	return string(b)
+}
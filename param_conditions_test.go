@@ -0,0 +1,204 @@
+package structemplate
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestActiveParams(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "EXPOSE", Default: "service"},
+		{ParamCode: "INGRESS_HOST", VisibleIf: &ParamCondition{Param: "EXPOSE", Equals: "ingress"}},
+		{ParamCode: "INGRESS_TLS", VisibleIf: &ParamCondition{Param: "INGRESS_HOST"}},
+		{ParamCode: "PORT", ValueDataType: DataTypeInt},
+		{ParamCode: "NODE_PORT", VisibleIf: &ParamCondition{AllOf: []ParamCondition{
+			{Param: "EXPOSE", In: []interface{}{"nodePort", "loadBalancer"}},
+			{Not: &ParamCondition{Param: "PORT", Equals: 80}},
+		}}},
+	}
+
+	cases := []struct {
+		values ParamValuesMap
+		active []string
+	}{
+		{ParamValuesMap{"INGRESS_HOST": "a.example.com"}, []string{"EXPOSE", "PORT"}},
+		{ParamValuesMap{"EXPOSE": "ingress", "INGRESS_HOST": "a.example.com"}, []string{"EXPOSE", "INGRESS_HOST", "INGRESS_TLS", "PORT"}},
+		{ParamValuesMap{"EXPOSE": "ingress"}, []string{"EXPOSE", "INGRESS_HOST", "PORT"}},
+		{ParamValuesMap{"EXPOSE": "nodePort", "PORT": "80"}, []string{"EXPOSE", "PORT"}},
+		{ParamValuesMap{"EXPOSE": "nodePort", "PORT": "8080"}, []string{"EXPOSE", "PORT", "NODE_PORT"}},
+	}
+	for i, c := range cases {
+		active, err := ActiveParams(params, c.values)
+		if err != nil {
+			t.Logf("Case %d: %v", i, err)
+			t.FailNow()
+			return
+		}
+		codes := make([]string, 0, len(active))
+		for _, p := range active {
+			codes = append(codes, p.ParamCode)
+		}
+		if len(codes) != len(c.active) {
+			t.Logf("Case %d: unexpected active params: %v", i, codes)
+			t.FailNow()
+			return
+		}
+		for j := range codes {
+			if codes[j] != c.active[j] {
+				t.Logf("Case %d: unexpected active params: %v", i, codes)
+				t.FailNow()
+				return
+			}
+		}
+	}
+}
+
+func TestActiveParams_Cycle(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "A", VisibleIf: &ParamCondition{Param: "B"}},
+		{ParamCode: "B", VisibleIf: &ParamCondition{Param: "A"}},
+		{ParamCode: "C"},
+	}
+	active, err := ActiveParams(params, ParamValuesMap{"A": 1, "B": 2})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 2 || errs[0].Code != ErrCodeInvalidCondition || len(active) != 1 || active[0].ParamCode != "C" {
+		t.Logf("Unexpected result: %v %v", active, err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestCheckParamConditions(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "EXPOSE", Default: "service"},
+		{ParamCode: "INGRESS_HOST", RequiredIf: &ParamCondition{Param: "EXPOSE", Equals: "ingress"}},
+		{ParamCode: "TLS_CERT", DependsOn: []string{"TLS_KEY"}},
+		{ParamCode: "TLS_KEY", DependsOn: []string{"TLS_CERT"}},
+		{ParamCode: "PASSWORD", ConflictsWith: []string{"PASSWORD_SECRET"}},
+		{ParamCode: "PASSWORD_SECRET", ConflictsWith: []string{"PASSWORD"}},
+	}
+
+	if err := CheckParamConditions(params, ParamValuesMap{"TLS_CERT": "c", "TLS_KEY": "k", "PASSWORD": "p"}); err != nil {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+
+	err := CheckParamConditions(params, ParamValuesMap{"EXPOSE": "ingress", "TLS_CERT": "c", "PASSWORD": "p", "PASSWORD_SECRET": "s"})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 3 {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+	expected := []struct {
+		code  string
+		param string
+	}{
+		{ErrCodeRequiredIf, "INGRESS_HOST"},
+		{ErrCodeMissingDependency, "TLS_CERT"},
+		{ErrCodeConflict, "PASSWORD"},
+	}
+	for i, e := range expected {
+		if errs[i].Code != e.code || errs[i].ParamCode != e.param {
+			t.Logf("Unexpected error %d: %v", i, errs[i])
+			t.FailNow()
+			return
+		}
+	}
+	if msg := errs[0].Error(); msg != `param INGRESS_HOST is required when EXPOSE == "ingress"` {
+		t.Logf("Unexpected message: %s", msg)
+		t.FailNow()
+		return
+	}
+}
+
+func TestParamCondition_String(t *testing.T) {
+	c := &ParamCondition{AnyOf: []ParamCondition{
+		{Param: "EXPOSE", In: []interface{}{"ingress", "route"}},
+		{Param: "HOST", Exists: boolPtr(false)},
+	}, Not: &ParamCondition{Param: "TLS"}}
+	if s := c.String(); s != `(EXPOSE in ["ingress","route"] || !HOST) && !(TLS)` {
+		t.Logf("Unexpected string: %s", s)
+		t.FailNow()
+		return
+	}
+}
+
+func TestTemplateRender_HiddenParams(t *testing.T) {
+	deployGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	tmpl := NewTemplate("app", templateManifest, []TemplateDynamicParam{
+		{ParamCode: "APP_NAME", ParamType: ParamTypeStrSlot, Default: "demo"},
+		{ParamCode: "NAMESPACE", ParamType: ParamTypeStrSlot, Default: "prod",
+			VisibleIf: &ParamCondition{Param: "MULTI_TENANT", Equals: true}},
+		{ParamCode: "MULTI_TENANT", ValueDataType: DataTypeBoolean, Default: false},
+		{ParamCode: "REPLICAS", ParamType: ParamTypeJsonPath, Default: 3,
+			VisibleIf:          &ParamCondition{Param: "MULTI_TENANT", Equals: true},
+			ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: deployGVK, ParamJsonPath: ".spec.replicas"}}},
+	})
+
+	objs, err := tmpl.Render(ParamValuesMap{"NAMESPACE": "ignored", "REPLICAS": 5})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	if objs[0].GetNamespace() != "default" {
+		t.Logf("Hidden StrSlot param should not be injected: %s", objs[0].GetNamespace())
+		t.FailNow()
+		return
+	}
+	if replicas := objs[1].Object["spec"].(map[string]interface{})["replicas"]; replicas != int64(1) {
+		t.Logf("Hidden JsonPath param should not be injected: %v", replicas)
+		t.FailNow()
+		return
+	}
+
+	objs, err = tmpl.Render(ParamValuesMap{"MULTI_TENANT": "true"})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	if objs[0].GetNamespace() != "prod" {
+		t.Logf("Visible StrSlot param default should be injected: %s", objs[0].GetNamespace())
+		t.FailNow()
+		return
+	}
+	if replicas := objs[1].Object["spec"].(map[string]interface{})["replicas"]; replicas != int64(3) {
+		t.Logf("Visible JsonPath param default should be injected: %v", replicas)
+		t.FailNow()
+		return
+	}
+}
+
+func TestTemplateRender_VisibilityResolvedOnce(t *testing.T) {
+	tmpl := NewTemplate("app", templateManifest, []TemplateDynamicParam{
+		{ParamCode: "APP_NAME", ParamType: ParamTypeStrSlot, Default: "demo"},
+		{ParamCode: "TOKEN", Generator: &ValueGenerator{Kind: GeneratorToken}},
+		// hidden before TOKEN is generated, and stays hidden for the rest of the rendering
+		{ParamCode: "TOKEN_SECRET", VisibleIf: &ParamCondition{Param: "TOKEN"}, RequiredIf: &ParamCondition{Param: "APP_NAME"}},
+	})
+	if _, err := tmpl.Render(nil); err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	if err := tmpl.Validate(nil); err != nil {
+		t.Logf("Failed validate template: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	err := tmpl.Validate(ParamValuesMap{"TOKEN": "provided"})
+	if errs, ok := err.(ParamValueErrors); !ok || len(errs) != 1 || errs[0].Code != ErrCodeRequiredIf || errs[0].ParamCode != "TOKEN_SECRET" {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
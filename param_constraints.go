@@ -0,0 +1,222 @@
+package structemplate
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// imageRefRegexp matches container image references: [registry[:port]/]repository[:tag][@digest]
+var imageRefRegexp = regexp.MustCompile(`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+// maxCachedConstraintPatterns bounds the count of compiled patterns kept by compileConstraintPattern
+const maxCachedConstraintPatterns = 1024
+
+// compiled patterns of constraints, the patterns come from param definitions so the cache is bounded
+var constraintPatterns = newLRUCache[*regexp.Regexp](maxCachedConstraintPatterns)
+
+// CheckParamConstraints checks a param value against the Constraints of the param.
+// Items constraints (MinItems, MaxItems, UniqueItems) apply to array values, the other constraints
+// apply to scalar values and to every element of array values, the Index of their errors is the element index.
+// The error is a ParamValueErrors listing every violated constraint.
+func CheckParamConstraints(param *TemplateDynamicParam, value interface{}) error {
+	c := param.Constraints
+	if c == nil || value == nil {
+		return nil
+	}
+
+	errs := make(ParamValueErrors, 0)
+	add := func(v constraintViolation, index *int) {
+		errs = append(errs, &ParamValueError{Code: v.code, ParamCode: param.ParamCode, DataType: param.ValueDataType,
+			Index: index, Value: value, Limit: v.limit, Reason: v.reason})
+	}
+
+	if _, isString := value.(string); !isString && reflect.ValueOf(value).Kind() == reflect.Slice {
+		elems := toInterfaceSlice(value)
+		if c.MinItems != nil && len(elems) < *c.MinItems {
+			add(constraintViolation{ErrCodeMinItems, *c.MinItems, fmt.Sprintf("at least %d items are required", *c.MinItems)}, nil)
+		}
+		if c.MaxItems != nil && len(elems) > *c.MaxItems {
+			add(constraintViolation{ErrCodeMaxItems, *c.MaxItems, fmt.Sprintf("at most %d items are allowed", *c.MaxItems)}, nil)
+		}
+		if c.UniqueItems {
+			if i, j, ok := findDuplicateItems(elems); ok {
+				add(constraintViolation{ErrCodeUniqueItems, nil, fmt.Sprintf("items %d and %d are duplicated", i, j)}, nil)
+			}
+		}
+		for i, elem := range elems {
+			index := i
+			for _, v := range checkScalarConstraints(c, elem) {
+				add(v, &index)
+			}
+		}
+	} else {
+		for _, v := range checkScalarConstraints(c, value) {
+			add(v, nil)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// constraintViolation is a violated constraint of a scalar value.
+type constraintViolation struct {
+	code   string
+	limit  interface{}
+	reason string
+}
+
+func checkScalarConstraints(c *ParamConstraints, value interface{}) []constraintViolation {
+	violations := make([]constraintViolation, 0)
+	if n, ok := toFloat64(value); ok {
+		if c.Minimum != nil && n < *c.Minimum {
+			violations = append(violations, constraintViolation{ErrCodeMinimum, *c.Minimum,
+				fmt.Sprintf("must be greater than or equal to %v", *c.Minimum)})
+		}
+		if c.Maximum != nil && n > *c.Maximum {
+			violations = append(violations, constraintViolation{ErrCodeMaximum, *c.Maximum,
+				fmt.Sprintf("must be less than or equal to %v", *c.Maximum)})
+		}
+	}
+	if s, ok := value.(string); ok {
+		length := utf8.RuneCountInString(s)
+		if c.MinLength != nil && length < *c.MinLength {
+			violations = append(violations, constraintViolation{ErrCodeMinLength, *c.MinLength,
+				fmt.Sprintf("length must be at least %d", *c.MinLength)})
+		}
+		if c.MaxLength != nil && length > *c.MaxLength {
+			violations = append(violations, constraintViolation{ErrCodeMaxLength, *c.MaxLength,
+				fmt.Sprintf("length must be at most %d", *c.MaxLength)})
+		}
+		if c.Pattern != "" {
+			pattern, err := compileConstraintPattern(c.Pattern)
+			if err != nil {
+				violations = append(violations, constraintViolation{ErrCodeInvalidConstraint, c.Pattern, err.Error()})
+			} else if !pattern.MatchString(s) {
+				violations = append(violations, constraintViolation{ErrCodePattern, c.Pattern,
+					fmt.Sprintf("must match the pattern %s", c.Pattern)})
+			}
+		}
+	}
+	if c.Format != "" {
+		if !isKnownFormat(c.Format) {
+			violations = append(violations, constraintViolation{ErrCodeInvalidConstraint, c.Format, "unknown format " + c.Format})
+		} else if reason := checkFormat(c.Format, value); reason != "" {
+			violations = append(violations, constraintViolation{ErrCodeFormat, c.Format, reason})
+		}
+	}
+	if len(c.Enum) > 0 && !containsJSONValue(c.Enum, value) {
+		violations = append(violations, constraintViolation{ErrCodeEnum, c.Enum, "must be one of the enum values"})
+	}
+	return violations
+}
+
+func compileConstraintPattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := constraintPatterns.get(pattern); ok {
+		return cached, nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+	}
+	constraintPatterns.add(pattern, compiled)
+	return compiled, nil
+}
+
+func isKnownFormat(format string) bool {
+	switch format {
+	case FormatDNS1123Label, FormatDNS1123Subdomain, FormatLabelValue, FormatQuantity, FormatImage, FormatCIDR, FormatIP, FormatPort:
+		return true
+	}
+	return false
+}
+
+// checkFormat returns the reason why value does not have the format, or "" if it does.
+func checkFormat(format string, value interface{}) string {
+	if format == FormatPort {
+		port, ok := toFloat64(value)
+		if s, isString := value.(string); isString {
+			n, err := strconv.Atoi(s)
+			port, ok = float64(n), err == nil
+		}
+		if !ok || port != float64(int(port)) || len(validation.IsValidPortNum(int(port))) > 0 {
+			return "must be a port number between 1 and 65535"
+		}
+		return ""
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Sprintf("must be a string of format %s", format)
+	}
+	var msgs []string
+	switch format {
+	case FormatDNS1123Label:
+		msgs = validation.IsDNS1123Label(s)
+	case FormatDNS1123Subdomain:
+		msgs = validation.IsDNS1123Subdomain(s)
+	case FormatLabelValue:
+		msgs = validation.IsValidLabelValue(s)
+	case FormatQuantity:
+		if _, err := resource.ParseQuantity(s); err != nil {
+			return "must be a resource quantity like 500m or 1Gi"
+		}
+	case FormatImage:
+		if len(s) > 255 || !imageRefRegexp.MatchString(s) {
+			return "must be a container image reference"
+		}
+	case FormatCIDR:
+		if _, _, err := net.ParseCIDR(s); err != nil {
+			return "must be a CIDR like 10.0.0.0/16"
+		}
+	case FormatIP:
+		if net.ParseIP(s) == nil {
+			return "must be an IP address"
+		}
+	default:
+		return "unknown format " + format
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// findDuplicateItems returns the indexes of the first pair of deeply equal elements.
+func findDuplicateItems(elems []interface{}) (int, int, bool) {
+	for i := range elems {
+		for j := i + 1; j < len(elems); j++ {
+			if jsonValueEqual(normalizeJSONValue(elems[i]), normalizeJSONValue(elems[j])) {
+				return i, j, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func containsJSONValue(values []interface{}, value interface{}) bool {
+	normalized := normalizeJSONValue(value)
+	for _, v := range values {
+		if jsonValueEqual(normalizeJSONValue(v), normalized) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSONValue converts value with ToJSONValue, values that cannot be converted are returned as they are.
+func normalizeJSONValue(value interface{}) interface{} {
+	if normalized, err := ToJSONValue(value); err == nil {
+		return normalized
+	}
+	return value
+}
@@ -0,0 +1,82 @@
+package structemplate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestCheckParamConstraints(t *testing.T) {
+	cases := []struct {
+		constraints ParamConstraints
+		valid       []interface{}
+		invalid     []interface{}
+	}{
+		{ParamConstraints{Pattern: "^v[0-9]+$"}, []interface{}{"v1"}, []interface{}{"1", "v1a"}},
+		{ParamConstraints{Minimum: floatPtr(1), Maximum: floatPtr(10)}, []interface{}{int64(1), 10.0}, []interface{}{0, 10.5}},
+		{ParamConstraints{MinLength: intPtr(2), MaxLength: intPtr(3)}, []interface{}{"ab", "名字吗"}, []interface{}{"a", "abcd"}},
+		{ParamConstraints{MinItems: intPtr(1), MaxItems: intPtr(2), UniqueItems: true}, []interface{}{[]interface{}{"a", "b"}}, []interface{}{[]interface{}{}, []interface{}{"a", "b", "c"}, []interface{}{int64(1), 1.0}}},
+		{ParamConstraints{Enum: []interface{}{"a", 1}}, []interface{}{"a", int64(1), []interface{}{"a", 1}}, []interface{}{"b", []interface{}{"a", "b"}}},
+		{ParamConstraints{Format: FormatDNS1123Label}, []interface{}{"my-app"}, []interface{}{"My_App", strings.Repeat("a", 64)}},
+		{ParamConstraints{Format: FormatDNS1123Subdomain}, []interface{}{"app.example.com"}, []interface{}{"app..com"}},
+		{ParamConstraints{Format: FormatQuantity}, []interface{}{"500m", "1Gi"}, []interface{}{"1GB", "abc"}},
+		{ParamConstraints{Format: FormatImage}, []interface{}{"nginx", "docker.io/library/nginx:1.25", "localhost:5000/app@sha256:" + strings.Repeat("a", 64)}, []interface{}{"Nginx", "nginx:", "nginx:tag with space"}},
+		{ParamConstraints{Format: FormatCIDR}, []interface{}{"10.0.0.0/16", "fd00::/8"}, []interface{}{"10.0.0.0", "10.0.0.0/33"}},
+		{ParamConstraints{Format: FormatIP}, []interface{}{"10.0.0.1"}, []interface{}{"10.0.0.256"}},
+		{ParamConstraints{Format: FormatPort}, []interface{}{int64(80), "443"}, []interface{}{0, 65536, "http", 80.5}},
+	}
+	for i, c := range cases {
+		param := &TemplateDynamicParam{ParamCode: "P", Constraints: &c.constraints}
+		for _, v := range c.valid {
+			if err := CheckParamConstraints(param, v); err != nil {
+				t.Logf("Case %d: value %v should be valid: %v", i, v, err)
+				t.FailNow()
+				return
+			}
+		}
+		for _, v := range c.invalid {
+			if _, ok := CheckParamConstraints(param, v).(ParamValueErrors); !ok {
+				t.Logf("Case %d: value %v should be invalid", i, v)
+				t.FailNow()
+				return
+			}
+		}
+	}
+}
+
+func TestValidateParamValues_Constraints(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "NAMES", ValueDataType: "array[string]", Constraints: &ParamConstraints{Format: FormatDNS1123Label, MaxItems: intPtr(2)}},
+		{ParamCode: "REPLICAS", ValueDataType: DataTypeInt, Constraints: &ParamConstraints{Maximum: floatPtr(5)}},
+	}
+	_, err := ValidateParamValues(params, ParamValuesMap{"NAMES": []string{"a", "B", "c"}, "REPLICAS": "8"})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 3 || errs[1].Code != ErrCodeFormat || errs[1].Index == nil || *errs[1].Index != 1 || errs[2].ParamCode != "REPLICAS" || errs[2].Code != ErrCodeMaximum {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestConstraintPatternsCache(t *testing.T) {
+	for i := 0; i < maxCachedConstraintPatterns+10; i++ {
+		if _, err := compileConstraintPattern(fmt.Sprintf("^a%d$", i)); err != nil {
+			t.Logf("Failed compile pattern: %v", err)
+			t.FailNow()
+			return
+		}
+	}
+	if constraintPatterns.len() > maxCachedConstraintPatterns {
+		t.Logf("Cache is not bounded: %d", constraintPatterns.len())
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,358 @@
+package structemplate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// expressionSelf is the CEL variable holding the values of all the params.
+const expressionSelf = "self"
+
+// expressionCostLimit bounds the runtime cost of evaluating one expression, the same per-call limit as Kubernetes
+// validation rules, so expressions like nested comprehensions over large lists cannot hang the rendering.
+const expressionCostLimit = 1000000
+
+var celIdentifierRegexp = regexp.MustCompile(`^[_a-zA-Z][_a-zA-Z0-9]*$`)
+
+// reserved words of CEL, params with these codes are only available through `self`
+var celReservedWords = []string{"as", "break", "const", "continue", "else", "false", "for", "function", "if", "import",
+	"in", "let", "loop", "package", "namespace", "null", "return", "true", "var", "void", "while", expressionSelf}
+
+// EvaluateComputedParams evaluates the Expression of computed params and returns a copy of values with the results.
+// Expressions are written in CEL and reference other params by code like `NAME + "." + NAMESPACE + ".svc"`,
+// or through `self` like `self.NAME` and `self["my-param"]` for codes that are not CEL identifiers.
+// Defaults are used for the params without value, other params without value are absent
+// and `has(self.NAME)` tests whether a param has a value.
+// Computed params are evaluated in dependency order so they can use other computed params, cycles are rejected.
+// Provided values of computed params are replaced, and the results are coerced and checked like provided values,
+// see ValidateParamValues. The error is a ParamValueErrors.
+func EvaluateComputedParams(params []TemplateDynamicParam, values ParamValuesMap) (ParamValuesMap, error) {
+	result := make(ParamValuesMap, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+
+	computed := make(map[string]*TemplateDynamicParam)
+	order := make([]string, 0)
+	for i := range params {
+		if params[i].Expression == "" {
+			continue
+		}
+		if _, ok := computed[params[i].ParamCode]; !ok {
+			computed[params[i].ParamCode] = &params[i]
+			order = append(order, params[i].ParamCode)
+		}
+	}
+	if len(computed) < 1 {
+		return result, nil
+	}
+
+	env, err := newExpressionEnv(params, values)
+	if err != nil {
+		return nil, err
+	}
+	errs := make(ParamValueErrors, 0)
+	asts := make(map[string]*cel.Ast, len(computed))
+	deps := make(map[string][]string, len(computed))
+	for _, code := range order {
+		param := computed[code]
+		checked, iss := env.Compile(param.Expression)
+		if iss != nil && iss.Err() != nil {
+			errs = append(errs, &ParamValueError{Code: ErrCodeInvalidExpression, ParamCode: code, Limit: param.Expression, Reason: iss.Err().Error()})
+			continue
+		}
+		refs, err := expressionRefs(checked)
+		if err != nil {
+			return nil, err
+		}
+		asts[code] = checked
+		for _, r := range refs {
+			if _, ok := computed[r]; ok {
+				deps[code] = append(deps[code], r)
+			}
+		}
+	}
+
+	// evaluation order, every computed param after the computed params it references
+	sorted := make([]string, 0, len(asts))
+	state := make(map[string]int) // 1: visiting, 2: done
+	var visit func(code string, path []string) bool
+	visit = func(code string, path []string) bool {
+		switch state[code] {
+		case 1:
+			cycle := append(append([]string{}, path[indexOfString(path, code):]...), code)
+			errs = append(errs, &ParamValueError{Code: ErrCodeInvalidExpression, ParamCode: code, Limit: computed[code].Expression,
+				Reason: "cyclic expressions: " + strings.Join(cycle, " -> ")})
+			return false
+		case 2:
+			return true
+		}
+		state[code] = 1
+		ok := true
+		path = append(append([]string{}, path...), code)
+		for _, dep := range deps[code] {
+			if _, compiled := asts[dep]; !compiled || !visit(dep, path) {
+				ok = false
+			}
+		}
+		state[code] = 2
+		if ok {
+			sorted = append(sorted, code)
+		}
+		return ok
+	}
+	for _, code := range order {
+		if _, compiled := asts[code]; compiled {
+			visit(code, nil)
+		}
+	}
+
+	failed := make(map[string]bool)
+	for _, code := range sorted {
+		param := computed[code]
+		skip := false
+		for _, dep := range deps[code] {
+			skip = skip || failed[dep]
+		}
+		if skip {
+			failed[code] = true
+			continue
+		}
+		value, err := evalExpression(env, asts[code], withParamDefaults(params, result))
+		if err != nil {
+			failed[code] = true
+			errs = append(errs, &ParamValueError{Code: ErrCodeExpressionFailed, ParamCode: code, Limit: param.Expression, Reason: err.Error()})
+			continue
+		}
+		validated, err := ValidateParamValues([]TemplateDynamicParam{*param}, ParamValuesMap{code: value})
+		if err != nil {
+			failed[code] = true
+			errs = append(errs, err.(ParamValueErrors)...)
+			continue
+		}
+		result[code] = validated[code]
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return result, nil
+}
+
+// CheckValidationRules evaluates the rules with the param values, see ValidationRule.
+// The error is a ParamValueErrors listing every rule not satisfied, invalid or failing.
+func CheckValidationRules(rules []ValidationRule, params []TemplateDynamicParam, values ParamValuesMap) error {
+	if len(rules) < 1 {
+		return nil
+	}
+	env, err := newExpressionEnv(params, values)
+	if err != nil {
+		return err
+	}
+	errs := make(ParamValueErrors, 0)
+	for _, rule := range rules {
+		checked, iss := env.Compile(rule.Rule)
+		if iss != nil && iss.Err() != nil {
+			errs = append(errs, &ParamValueError{Code: ErrCodeInvalidExpression, ParamCode: rule.ParamCode, Limit: rule.Rule, Reason: iss.Err().Error()})
+			continue
+		}
+		result, err := evalExpression(env, checked, withParamDefaults(params, values))
+		if err != nil {
+			errs = append(errs, &ParamValueError{Code: ErrCodeExpressionFailed, ParamCode: rule.ParamCode, Limit: rule.Rule, Reason: err.Error()})
+			continue
+		}
+		ok, isBool := result.(bool)
+		if !isBool {
+			errs = append(errs, &ParamValueError{Code: ErrCodeInvalidExpression, ParamCode: rule.ParamCode, Limit: rule.Rule,
+				Reason: fmt.Sprintf("rule must return a bool, got %T", result)})
+			continue
+		}
+		if !ok {
+			message := rule.Message
+			if message == "" {
+				message = rule.Rule
+			}
+			errs = append(errs, &ParamValueError{Code: ErrCodeRuleViolated, ParamCode: rule.ParamCode, Limit: rule.Rule, Reason: message})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// newExpressionEnv declares `self` and a variable for every param code and value key that is a CEL identifier.
+func newExpressionEnv(params []TemplateDynamicParam, values ParamValuesMap) (*cel.Env, error) {
+	opts := []cel.EnvOption{
+		cel.Variable(expressionSelf, cel.MapType(cel.StringType, cel.DynType)),
+		ext.Strings(), ext.Encoders(), ext.Lists(),
+	}
+	declared := make(map[string]bool)
+	declare := func(name string) {
+		if !declared[name] && celIdentifierRegexp.MatchString(name) && !containsString(celReservedWords, name) {
+			declared[name] = true
+			opts = append(opts, cel.Variable(name, cel.DynType))
+		}
+	}
+	for i := range params {
+		declare(params[i].ParamCode)
+	}
+	for k := range values {
+		declare(k)
+	}
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create the CEL environment")
+	}
+	return env, nil
+}
+
+// withParamDefaults returns a copy of values with the defaults of the params without value.
+func withParamDefaults(params []TemplateDynamicParam, values ParamValuesMap) ParamValuesMap {
+	result := make(ParamValuesMap, len(values))
+	for i := range params {
+		if params[i].Default != nil && params[i].Expression == "" {
+			if _, exist := result[params[i].ParamCode]; !exist {
+				result[params[i].ParamCode] = params[i].Default
+			}
+		}
+	}
+	for k, v := range values {
+		result[k] = v
+	}
+	return result
+}
+
+// evalExpression evaluates a compiled expression with the values, values are available by name and through `self`.
+func evalExpression(env *cel.Env, checked *cel.Ast, values ParamValuesMap) (interface{}, error) {
+	prg, err := env.Program(checked, cel.CostLimit(expressionCostLimit))
+	if err != nil {
+		return nil, err
+	}
+	self := make(map[string]interface{}, len(values))
+	activation := map[string]interface{}{expressionSelf: self}
+	for k, v := range values {
+		if v == nil {
+			continue
+		}
+		v = normalizeJSONValue(v)
+		self[k] = v
+		if k != expressionSelf {
+			activation[k] = v
+		}
+	}
+	out, _, err := prg.Eval(activation)
+	if err != nil {
+		return nil, err
+	}
+	return celValueToJSON(out)
+}
+
+// celValueToJSON converts a CEL value to the JSON compatible values used by params.
+func celValueToJSON(val ref.Val) (interface{}, error) {
+	switch v := val.(type) {
+	case types.Int:
+		return int64(v), nil
+	case types.Uint:
+		return uint64(v), nil
+	case types.Double:
+		return float64(v), nil
+	case types.String:
+		return string(v), nil
+	case types.Bool:
+		return bool(v), nil
+	case types.Null:
+		return nil, nil
+	}
+	native, err := val.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, errors.Wrap(err, "unsupported result type "+val.Type().TypeName())
+	}
+	return native.(*structpb.Value).AsInterface(), nil
+}
+
+// expressionRefs lists the names referenced by an expression: identifiers and the fields of `self`
+// selected statically like `self.NAME` or `self["NAME"]`.
+func expressionRefs(checked *cel.Ast) ([]string, error) {
+	native := checked.NativeRep()
+	if native == nil {
+		return nil, errors.New("expression is not parsed")
+	}
+	refs := make([]string, 0)
+	isSelf := func(e ast.Expr) bool {
+		return e.Kind() == ast.IdentKind && e.AsIdent() == expressionSelf
+	}
+	var walk func(e ast.Expr)
+	walk = func(e ast.Expr) {
+		if e == nil {
+			return
+		}
+		switch e.Kind() {
+		case ast.IdentKind:
+			refs = appendUnique(refs, e.AsIdent())
+		case ast.SelectKind:
+			sel := e.AsSelect()
+			if isSelf(sel.Operand()) {
+				refs = appendUnique(refs, sel.FieldName())
+				return
+			}
+			walk(sel.Operand())
+		case ast.CallKind:
+			call := e.AsCall()
+			args := call.Args()
+			if call.FunctionName() == "_[_]" && len(args) == 2 && isSelf(args[0]) && args[1].Kind() == ast.LiteralKind {
+				if key, ok := args[1].AsLiteral().(types.String); ok {
+					refs = appendUnique(refs, string(key))
+					return
+				}
+			}
+			if call.IsMemberFunction() {
+				walk(call.Target())
+			}
+			for _, arg := range args {
+				walk(arg)
+			}
+		case ast.ListKind:
+			for _, elem := range e.AsList().Elements() {
+				walk(elem)
+			}
+		case ast.MapKind:
+			for _, entry := range e.AsMap().Entries() {
+				walk(entry.AsMapEntry().Key())
+				walk(entry.AsMapEntry().Value())
+			}
+		case ast.StructKind:
+			for _, field := range e.AsStruct().Fields() {
+				walk(field.AsStructField().Value())
+			}
+		case ast.ComprehensionKind:
+			c := e.AsComprehension()
+			walk(c.IterRange())
+			walk(c.AccuInit())
+			walk(c.LoopCondition())
+			walk(c.LoopStep())
+			walk(c.Result())
+		}
+	}
+	walk(native.Expr())
+	return refs, nil
+}
+
+func indexOfString(arr []string, s string) int {
+	for i, e := range arr {
+		if e == s {
+			return i
+		}
+	}
+	return -1
+}
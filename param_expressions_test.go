@@ -0,0 +1,170 @@
+package structemplate
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestEvaluateComputedParams(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "SERVICE_URL", Expression: `"http://" + SERVICE_FQDN + ":" + string(self.PORT)`},
+		{ParamCode: "SERVICE_FQDN", Expression: `NAME + "." + NAMESPACE + ".svc"`},
+		{ParamCode: "NAME"},
+		{ParamCode: "NAMESPACE"},
+		{ParamCode: "PORT", ValueDataType: DataTypeInt},
+		{ParamCode: "MAX_REPLICAS", ValueDataType: DataTypeInt, Expression: `has(self.REPLICAS) ? self.REPLICAS * 2 : 1`},
+		{ParamCode: "LABELS", Expression: `{"app": NAME, "tier": self["app-tier"]}`},
+	}
+	values, err := EvaluateComputedParams(params, ParamValuesMap{"NAME": "web", "NAMESPACE": "prod", "PORT": int64(8080), "app-tier": "frontend"})
+	if err != nil {
+		t.Logf("Failed evaluate computed params: %v", err)
+		t.FailNow()
+		return
+	}
+	if values["SERVICE_FQDN"] != "web.prod.svc" || values["SERVICE_URL"] != "http://web.prod.svc:8080" {
+		t.Logf("Unexpected values: %v", values)
+		t.FailNow()
+		return
+	}
+	if values["MAX_REPLICAS"] != int64(1) {
+		t.Logf("Unexpected MAX_REPLICAS: %#v", values["MAX_REPLICAS"])
+		t.FailNow()
+		return
+	}
+	labels, ok := values["LABELS"].(map[string]interface{})
+	if !ok || labels["app"] != "web" || labels["tier"] != "frontend" {
+		t.Logf("Unexpected LABELS: %#v", values["LABELS"])
+		t.FailNow()
+		return
+	}
+
+	values, err = EvaluateComputedParams(params, ParamValuesMap{"NAME": "web", "NAMESPACE": "prod", "PORT": 80, "REPLICAS": 3, "app-tier": "x"})
+	if err != nil || values["MAX_REPLICAS"] != int64(6) {
+		t.Logf("Unexpected result: %v %v", values, err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestEvaluateComputedParams_Errors(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "A", Expression: `B + "a"`},
+		{ParamCode: "B", Expression: `A + "b"`},
+		{ParamCode: "C", Expression: `C_INPUT +`},
+		{ParamCode: "D", Expression: `G + "d"`},
+		{ParamCode: "G"},
+		{ParamCode: "E", Expression: `D + "e"`},
+		{ParamCode: "F", ValueDataType: DataTypeInt, Expression: `"not a number"`},
+	}
+	_, err := EvaluateComputedParams(params, ParamValuesMap{})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 4 {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+	codes := make(map[string]string)
+	for _, e := range errs {
+		codes[e.ParamCode] = e.Code
+	}
+	if codes["C"] != ErrCodeInvalidExpression || codes["D"] != ErrCodeExpressionFailed || codes["F"] != ErrCodeInvalidType {
+		t.Logf("Unexpected errors: %v", err)
+		t.FailNow()
+		return
+	}
+	if !strings.Contains(err.Error(), "cyclic expressions: A -> B -> A") {
+		t.Logf("Cycle should be reported: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestCheckValidationRules(t *testing.T) {
+	rules := []ValidationRule{
+		{Rule: "REPLICAS <= self.MAX_REPLICAS", Message: "replicas must not exceed maxReplicas", ParamCode: "REPLICAS"},
+		{Rule: "NAME.startsWith('app-')"},
+		{Rule: "REPLICAS + 1"},
+	}
+	params := []TemplateDynamicParam{{ParamCode: "REPLICAS"}, {ParamCode: "MAX_REPLICAS"}, {ParamCode: "NAME"}}
+	err := CheckValidationRules(rules, params, ParamValuesMap{"REPLICAS": 5, "MAX_REPLICAS": 3, "NAME": "web"})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 3 {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+	if errs[0].Code != ErrCodeRuleViolated || errs[0].ParamCode != "REPLICAS" || errs[0].Message(LangChinese) != "校验规则不满足: replicas must not exceed maxReplicas" {
+		t.Logf("Unexpected error: %v", errs[0])
+		t.FailNow()
+		return
+	}
+	if errs[1].Reason != "NAME.startsWith('app-')" || errs[2].Code != ErrCodeInvalidExpression {
+		t.Logf("Unexpected errors: %v", err)
+		t.FailNow()
+		return
+	}
+
+	if err := CheckValidationRules(rules[:2], params, ParamValuesMap{"REPLICAS": 2, "MAX_REPLICAS": 3, "NAME": "app-web"}); err != nil {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestTemplateRender_ComputedParams(t *testing.T) {
+	tmpl := NewTemplate("app", templateManifest, []TemplateDynamicParam{
+		{ParamCode: "BASE_NAME", ParamType: ParamTypeStrSlot},
+		{ParamCode: "APP_NAME", ParamType: ParamTypeStrSlot, Expression: `BASE_NAME + "-v2"`},
+		{ParamCode: "MAX_REPLICAS", ValueDataType: DataTypeInt, Default: 4},
+		{ParamCode: "REPLICAS", ParamType: ParamTypeJsonPath, ValueDataType: DataTypeInt, Expression: `MAX_REPLICAS / 2`,
+			ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, ParamJsonPath: ".spec.replicas"}}},
+	})
+	tmpl.Rules = []ValidationRule{{Rule: "BASE_NAME.size() <= 10", Message: "name too long"}}
+
+	objs, err := tmpl.Render(ParamValuesMap{"BASE_NAME": "web", "MAX_REPLICAS": 6})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	if objs[1].GetName() != "web-v2" || objs[1].Object["spec"].(map[string]interface{})["replicas"] != int64(3) {
+		t.Logf("Unexpected Deployment: %v", objs[1].Object)
+		t.FailNow()
+		return
+	}
+
+	objs, err = tmpl.Render(ParamValuesMap{"BASE_NAME": "web"})
+	if err != nil || objs[1].Object["spec"].(map[string]interface{})["replicas"] != int64(2) {
+		t.Logf("Defaults should be used by expressions: %v", err)
+		t.FailNow()
+		return
+	}
+
+	err = tmpl.Validate(ParamValuesMap{"BASE_NAME": "a-very-long-name", "MAX_REPLICAS": 6})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 1 || errs[0].Code != ErrCodeRuleViolated {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestEvaluateComputedParams_CostLimit(t *testing.T) {
+	items := make([]interface{}, 2000)
+	for i := range items {
+		items[i] = int64(i)
+	}
+	params := []TemplateDynamicParam{
+		{ParamCode: "ITEMS"},
+		{ParamCode: "ALL_POSITIVE", Expression: `ITEMS.all(a, ITEMS.all(b, a + b >= 0))`},
+	}
+	_, err := EvaluateComputedParams(params, ParamValuesMap{"ITEMS": items})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 1 || errs[0].Code != ErrCodeExpressionFailed || !strings.Contains(errs[0].Reason, "cost limit exceeded") {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,299 @@
+package structemplate
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultPasswordCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// ValueGenerateOptions controls the generation of param values, see GenerateParamValues.
+type ValueGenerateOptions struct {
+	// Seed makes the generated values deterministic: the same seed and param code always generate the same value,
+	// so rendering a release again does not rotate its secrets. Use a secret unique to the release.
+	// TLS keys are stable and certificates are renewed with the same key, the certificates themselves are signed
+	// again on every generation, return them with Lookup to keep them.
+	// The seed is a secret and is never serialized.
+	Seed string `json:"-"`
+	// Lookup returns the value previously generated for a param, which is used instead of generating a new one.
+	Lookup func(paramCode string) (interface{}, bool) `json:"-"`
+}
+
+// GenerateParamValues returns a copy of values with generated values for the params with a Generator and no value.
+// Previously generated values returned by opts.Lookup are reused, other values are generated randomly or,
+// with opts.Seed, deterministically. The error is a ParamValueErrors.
+func GenerateParamValues(params []TemplateDynamicParam, values ParamValuesMap, opts ValueGenerateOptions) (ParamValuesMap, error) {
+	result := make(ParamValuesMap, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+
+	errs := make(ParamValueErrors, 0)
+	for i := range params {
+		param := &params[i]
+		if param.Generator == nil {
+			continue
+		}
+		if value, exist := result[param.ParamCode]; exist && value != nil {
+			continue
+		}
+		if opts.Lookup != nil {
+			if value, ok := opts.Lookup(param.ParamCode); ok && value != nil {
+				result[param.ParamCode] = value
+				continue
+			}
+		}
+		value, err := GenerateValue(param.Generator, generatorRandom(opts.Seed, param.ParamCode))
+		if err != nil {
+			errs = append(errs, &ParamValueError{Code: ErrCodeGenerateFailed, ParamCode: param.ParamCode, Limit: param.Generator.Kind, Reason: err.Error()})
+			continue
+		}
+		result[param.ParamCode] = value
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return result, nil
+}
+
+// GenerateValue generates a value of the generator kind with the random source.
+func GenerateValue(gen *ValueGenerator, random io.Reader) (interface{}, error) {
+	switch gen.Kind {
+	case GeneratorPassword:
+		length := gen.Length
+		if length <= 0 {
+			length = 16
+		}
+		charset := gen.Charset
+		if charset == "" {
+			charset = defaultPasswordCharset
+		}
+		return generatePassword(random, length, []rune(charset))
+	case GeneratorToken:
+		length := gen.Length
+		if length <= 0 {
+			length = 32
+		}
+		b := make([]byte, length)
+		if _, err := io.ReadFull(random, b); err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(b), nil
+	case GeneratorUUID:
+		return generateUUID(random)
+	case GeneratorSSHKey:
+		return generateSSHKey(random)
+	case GeneratorTLSCert:
+		return generateTLSCert(random, gen)
+	}
+	return nil, fmt.Errorf("unknown generator kind %s", gen.Kind)
+}
+
+// generatorRandom returns crypto/rand.Reader without seed, or a deterministic stream derived from the seed and param code.
+func generatorRandom(seed string, paramCode string) io.Reader {
+	if seed == "" {
+		return rand.Reader
+	}
+	mac := hmac.New(sha256.New, []byte(seed))
+	mac.Write([]byte(paramCode))
+	return &seededReader{key: mac.Sum(nil)}
+}
+
+// seededReader is a HMAC-SHA256 counter mode stream.
+type seededReader struct {
+	key     []byte
+	counter uint64
+	buf     []byte
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) < 1 {
+			mac := hmac.New(sha256.New, r.key)
+			block := make([]byte, 8)
+			binary.BigEndian.PutUint64(block, r.counter)
+			mac.Write(block)
+			r.buf = mac.Sum(nil)
+			r.counter++
+		}
+		copied := copy(p[n:], r.buf)
+		r.buf = r.buf[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+func generatePassword(random io.Reader, length int, charset []rune) (string, error) {
+	if len(charset) > 256 {
+		return "", errors.New("charset must not have more than 256 characters")
+	}
+	// rejection sampling keeps the characters uniformly distributed
+	limit := 256 - 256%len(charset)
+	result := make([]rune, 0, length)
+	b := make([]byte, 1)
+	for len(result) < length {
+		if _, err := io.ReadFull(random, b); err != nil {
+			return "", err
+		}
+		if int(b[0]) < limit {
+			result = append(result, charset[int(b[0])%len(charset)])
+		}
+	}
+	return string(result), nil
+}
+
+func generateUUID(random io.Reader) (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func generateSSHKey(random io.Reader) (interface{}, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := io.ReadFull(random, seed); err != nil {
+		return nil, err
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	pub := priv.Public().(ed25519.PublicKey)
+
+	pubBlob := sshString(nil, []byte("ssh-ed25519"))
+	pubBlob = sshString(pubBlob, pub)
+
+	// openssh-key-v1 format without encryption, see PROTOCOL.key of OpenSSH
+	check := make([]byte, 4)
+	if _, err := io.ReadFull(random, check); err != nil {
+		return nil, err
+	}
+	private := append(append([]byte{}, check...), check...)
+	private = sshString(private, []byte("ssh-ed25519"))
+	private = sshString(private, pub)
+	private = sshString(private, priv)
+	private = sshString(private, nil) // comment
+	for i := byte(1); len(private)%8 != 0; i++ {
+		private = append(private, i)
+	}
+	key := []byte("openssh-key-v1\x00")
+	key = sshString(key, []byte("none"))
+	key = sshString(key, []byte("none"))
+	key = sshString(key, nil)
+	key = binary.BigEndian.AppendUint32(key, 1)
+	key = sshString(key, pubBlob)
+	key = sshString(key, private)
+
+	return map[string]interface{}{
+		"privateKey": string(pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: key})),
+		"publicKey":  "ssh-ed25519 " + base64.StdEncoding.EncodeToString(pubBlob),
+	}, nil
+}
+
+// sshString appends a length-prefixed string of the SSH wire format.
+func sshString(b []byte, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// generateTLSCert issues a self-signed certificate with an ECDSA P-256 key, accepted by browsers and TLS clients.
+// With a seeded random source the key is derived from the seed and the certificate is valid for fixed periods
+// of ValidDays, aligned by an offset derived from the seed, so it is renewed with the same key when it expires.
+// ECDSA signatures are randomized, the same certificate is only returned by ValueGenerateOptions.Lookup.
+// Otherwise the certificate is valid from now on.
+func generateTLSCert(random io.Reader, gen *ValueGenerator) (interface{}, error) {
+	key, err := generateP256Key(random)
+	if err != nil {
+		return nil, err
+	}
+	serial := make([]byte, 16)
+	if _, err := io.ReadFull(random, serial); err != nil {
+		return nil, err
+	}
+	validDays := gen.ValidDays
+	if validDays <= 0 {
+		validDays = 365
+	}
+	commonName := gen.CommonName
+	if commonName == "" && len(gen.DNSNames) > 0 {
+		commonName = gen.DNSNames[0]
+	}
+
+	now := time.Now()
+	notBefore := now.Add(-time.Hour).Truncate(time.Hour)
+	if _, seeded := random.(*seededReader); seeded {
+		b := make([]byte, 8)
+		if _, err := io.ReadFull(random, b); err != nil {
+			return nil, err
+		}
+		period := int64(validDays) * 24 * 3600
+		offset := int64(binary.BigEndian.Uint64(b) % uint64(period))
+		periods := (now.Unix() - offset) / period
+		if now.Unix() < offset {
+			periods--
+		}
+		notBefore = time.Unix(offset+periods*period, 0).UTC()
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetBytes(serial),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              gen.DNSNames,
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(0, 0, validDays),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create the certificate")
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"tls.crt": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		"tls.key": string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})),
+	}, nil
+}
+
+// generateP256Key derives a P-256 key from the random source, the key generation of crypto/ecdsa
+// does not produce the same key from the same seeded stream.
+func generateP256Key(random io.Reader) (*ecdsa.PrivateKey, error) {
+	d := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(random, d); err != nil {
+			return nil, err
+		}
+		priv, err := ecdh.P256().NewPrivateKey(d)
+		if err != nil {
+			// out of the range of the curve order, read again
+			continue
+		}
+		pub := priv.PublicKey().Bytes() // uncompressed point 0x04 || X || Y
+		return &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(pub[1:33]), Y: new(big.Int).SetBytes(pub[33:])},
+			D:         new(big.Int).SetBytes(d),
+		}, nil
+	}
+}
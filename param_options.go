@@ -0,0 +1,59 @@
+package structemplate
+
+import "fmt"
+
+// AllOptions returns the preset values of the param, AvailableOptions first followed by Options.
+func (p *TemplateDynamicParam) AllOptions() []ParamOption {
+	options := make([]ParamOption, 0, len(p.AvailableOptions)+len(p.Options))
+	for _, v := range p.AvailableOptions {
+		options = append(options, ParamOption{Value: v})
+	}
+	return append(options, p.Options...)
+}
+
+// DisplayLabel returns the label of the option, or the value formatted as a string when the label is empty.
+func (o *ParamOption) DisplayLabel() string {
+	if o.Label != "" {
+		return o.Label
+	}
+	if s, err := stringifyValue(o.Value); err == nil {
+		return s
+	}
+	return fmt.Sprintf("%v", o.Value)
+}
+
+// CheckParamOptions rejects values that are not one of the preset options of a param whose Customizable is false.
+// Values are compared deeply after being coerced to the ValueDataType of the param, so `3` matches the option `"3"`
+// of an int param and objects or arrays match regardless of their Go types. Params without options accept any value.
+// The error is a *ParamValueError.
+func CheckParamOptions(param *TemplateDynamicParam, value interface{}) error {
+	options := param.AllOptions()
+	if param.Customizable || len(options) < 1 || value == nil {
+		return nil
+	}
+
+	normalized, err := normalizeOptionValue(param, value)
+	if err != nil {
+		return &ParamValueError{Code: ErrCodeInvalidType, ParamCode: param.ParamCode, DataType: param.ValueDataType, Value: value, Reason: err.Error()}
+	}
+	for _, option := range options {
+		optionValue, err := normalizeOptionValue(param, option.Value)
+		if err != nil {
+			// an invalid option never matches
+			continue
+		}
+		if jsonValueEqual(normalized, optionValue) {
+			return nil
+		}
+	}
+	return &ParamValueError{Code: ErrCodeNotInOptions, ParamCode: param.ParamCode, DataType: param.ValueDataType, Value: value, Reason: "value is not one of the available options"}
+}
+
+func normalizeOptionValue(param *TemplateDynamicParam, value interface{}) (interface{}, error) {
+	if param.ValueDataType != "" {
+		if coerced, err := coerceValue(param.ValueDataType, value); err == nil {
+			return coerced, nil
+		}
+	}
+	return ToJSONValue(value)
+}
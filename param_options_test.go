@@ -0,0 +1,65 @@
+package structemplate
+
+import "testing"
+
+func TestCheckParamOptions(t *testing.T) {
+	param := &TemplateDynamicParam{
+		ParamCode:        "SIZE",
+		ValueDataType:    DataTypeInt,
+		AvailableOptions: []interface{}{1, "2"},
+		Options: []ParamOption{
+			{Value: 4, Label: "Large", Description: "4 replicas"},
+		},
+	}
+	for _, v := range []interface{}{int64(1), 2, "4"} {
+		if err := CheckParamOptions(param, v); err != nil {
+			t.Logf("Value %v should be accepted: %v", v, err)
+			t.FailNow()
+			return
+		}
+	}
+	if err := CheckParamOptions(param, 3); err == nil {
+		t.Logf("Value 3 should be rejected")
+		t.FailNow()
+		return
+	}
+	param.Customizable = true
+	if err := CheckParamOptions(param, 3); err != nil {
+		t.Logf("Customizable param should accept any value: %v", err)
+		t.FailNow()
+		return
+	}
+
+	options := param.AllOptions()
+	if len(options) != 3 || options[0].DisplayLabel() != "1" || options[2].DisplayLabel() != "Large" {
+		t.Logf("Unexpected options: %+v", options)
+		t.FailNow()
+		return
+	}
+}
+
+func TestCheckParamOptions_Objects(t *testing.T) {
+	param := &TemplateDynamicParam{
+		ParamCode: "RESOURCES",
+		Options: []ParamOption{
+			{Value: map[string]interface{}{"cpu": "1", "ports": []interface{}{80, 443}}, Label: "Small"},
+		},
+	}
+	if err := CheckParamOptions(param, map[string]interface{}{"ports": []int64{80, 443}, "cpu": "1"}); err != nil {
+		t.Logf("Deeply equal object should be accepted: %v", err)
+		t.FailNow()
+		return
+	}
+	if err := CheckParamOptions(param, map[string]interface{}{"cpu": "1", "ports": []interface{}{443, 80}}); err == nil {
+		t.Logf("Different object should be rejected")
+		t.FailNow()
+		return
+	}
+
+	_, err := ValidateParamValues([]TemplateDynamicParam{*param}, ParamValuesMap{"RESOURCES": map[string]interface{}{"cpu": "2"}})
+	if errs, ok := err.(ParamValueErrors); !ok || len(errs) != 1 || errs[0].ParamCode != "RESOURCES" {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,299 @@
+package structemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+)
+
+// ValidateParamValues validates the values of params with a ValueDataType and returns a copy of values with the
+// coerced values, see CoerceParamValue. Values of params without ValueDataType and unknown values are copied as they are.
+// Values of params that are not Customizable must be one of the preset options, see CheckParamOptions,
+// and the Constraints of params are checked, see CheckParamConstraints.
+// The returned error is a ParamValueErrors listing every invalid value.
+func ValidateParamValues(params []TemplateDynamicParam, values ParamValuesMap) (ParamValuesMap, error) {
+	result := make(ParamValuesMap, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+
+	errs := make(ParamValueErrors, 0)
+	for i := range params {
+		param := &params[i]
+		value, ok := result[param.ParamCode]
+		if !ok || value == nil {
+			continue
+		}
+		coerced, err := CoerceParamValue(param, value)
+		if err == nil {
+			err = CheckParamOptions(param, coerced)
+		}
+		if err != nil {
+			errs = append(errs, err.(*ParamValueError))
+			continue
+		}
+		if err := CheckParamConstraints(param, coerced); err != nil {
+			errs = append(errs, err.(ParamValueErrors)...)
+			continue
+		}
+		result[param.ParamCode] = coerced
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return result, nil
+}
+
+// validateParamDefaults checks the defaults used by the params without value like ValidateParamValues,
+// the Reason of the errors tells that the default value is invalid.
+func validateParamDefaults(params []TemplateDynamicParam, values ParamValuesMap) error {
+	defaults := make(ParamValuesMap)
+	for i := range params {
+		if params[i].Default == nil || params[i].Expression != "" {
+			continue
+		}
+		if _, exist := values[params[i].ParamCode]; !exist {
+			defaults[params[i].ParamCode] = params[i].Default
+		}
+	}
+	if _, err := ValidateParamValues(params, defaults); err != nil {
+		errs := err.(ParamValueErrors)
+		for _, e := range errs {
+			e.Reason = strings.TrimSuffix("invalid default value: "+e.Reason, ": ")
+		}
+		return errs
+	}
+	return nil
+}
+
+// CoerceParamValue converts value to the ValueDataType of the param:
+//   - int: integers, floats without fraction and numeric strings, as int64
+//   - float: numbers and numeric strings, as float64
+//   - boolean: bools and strings accepted by strconv.ParseBool
+//   - string: strings, numbers and bools are formatted, objects and arrays are rejected
+//   - object: maps, structs and JSON object strings, as map[string]interface{}
+//   - array, array[<type>]: slices and JSON array strings, as []interface{} with every element coerced to <type>
+//
+// For JsonPath params appending to arrays (AppendArray without InjectMode) ValueDataType is the type of the appended elements, the value is one element
+// or a slice of elements which are coerced one by one.
+// nil values and params without ValueDataType are returned as they are. The error is a *ParamValueError.
+func CoerceParamValue(param *TemplateDynamicParam, value interface{}) (interface{}, error) {
+	if value == nil || param.ValueDataType == "" {
+		return value, nil
+	}
+	var coerced interface{}
+	var err error
+	if param.AppendArray && param.ParamType == ParamTypeJsonPath && param.InjectMode == "" {
+		coerced, err = coerceElements(param.ValueDataType, value)
+	} else {
+		coerced, err = coerceValue(param.ValueDataType, value)
+	}
+	if err != nil {
+		code := ErrCodeInvalidType
+		if !isKnownDataType(param.ValueDataType) {
+			code = ErrCodeUnknownDataType
+		}
+		return nil, &ParamValueError{Code: code, ParamCode: param.ParamCode, DataType: param.ValueDataType, Value: value, Reason: err.Error()}
+	}
+	return coerced, nil
+}
+
+func coerceValue(dataType string, value interface{}) (interface{}, error) {
+	dataType = strings.TrimSpace(dataType)
+	if strings.HasPrefix(dataType, DataTypeArray+"[") && strings.HasSuffix(dataType, "]") {
+		return coerceArray(dataType[len(DataTypeArray)+1:len(dataType)-1], value)
+	}
+
+	switch strings.ToLower(dataType) {
+	case DataTypeInt, "integer":
+		return coerceInt(value)
+	case DataTypeFloat, "number", "double":
+		return coerceFloat(value)
+	case DataTypeBoolean, "bool":
+		return coerceBool(value)
+	case DataTypeString:
+		return coerceString(value)
+	case DataTypeObject, "map":
+		return coerceObject(value)
+	case DataTypeArray:
+		return coerceArray("", value)
+	}
+	return nil, fmt.Errorf("unknown data type %s", dataType)
+}
+
+// coerceElements coerces the value of an AppendArray param, a single element or a slice of elements of dataType.
+func coerceElements(dataType string, value interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return coerceValue(dataType, value)
+	}
+	result := make([]interface{}, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		coerced, err := coerceValue(dataType, rv.Index(i).Interface())
+		if err != nil {
+			// a single element of an array data type
+			if single, singleErr := coerceValue(dataType, value); singleErr == nil {
+				return single, nil
+			}
+			return nil, errors.Wrap(err, fmt.Sprintf("element %d", i))
+		}
+		result[i] = coerced
+	}
+	return result, nil
+}
+
+// isKnownDataType reports whether dataType and the element type of array[<type>] are supported by coerceValue.
+func isKnownDataType(dataType string) bool {
+	dataType = strings.TrimSpace(dataType)
+	if strings.HasPrefix(dataType, DataTypeArray+"[") && strings.HasSuffix(dataType, "]") {
+		return isKnownDataType(dataType[len(DataTypeArray)+1 : len(dataType)-1])
+	}
+	switch strings.ToLower(dataType) {
+	case DataTypeInt, "integer", DataTypeFloat, "number", "double", DataTypeBoolean, "bool", DataTypeString, DataTypeObject, "map", DataTypeArray:
+		return true
+	}
+	return false
+}
+
+func coerceInt(value interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int64", rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		// float64(math.MaxInt64) rounds up to 2^63 which overflows int64, -2^63 is exact
+		if f != math.Trunc(f) || f >= math.MaxInt64 || f < math.MinInt64 {
+			return nil, fmt.Errorf("%v is not an integer", f)
+		}
+		return int64(f), nil
+	case reflect.String:
+		s := strings.TrimSpace(rv.String())
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", s)
+		}
+		return i, nil
+	}
+	return nil, fmt.Errorf("%T cannot be converted to an integer", value)
+}
+
+func coerceFloat(value interface{}) (interface{}, error) {
+	if f, ok := toFloat64(value); ok {
+		return f, nil
+	}
+	if s, ok := value.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", s)
+		}
+		return f, nil
+	}
+	if n, ok := value.(json.Number); ok {
+		return n.Float64()
+	}
+	return nil, fmt.Errorf("%T cannot be converted to a number", value)
+}
+
+func coerceBool(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", v)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%T cannot be converted to a boolean", value)
+}
+
+func coerceString(value interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64), nil
+	}
+	return nil, fmt.Errorf("%T cannot be converted to a string", value)
+}
+
+func coerceObject(value interface{}) (interface{}, error) {
+	if s, ok := value.(string); ok {
+		obj := make(map[string]interface{})
+		if err := utiljson.Unmarshal([]byte(s), &obj); err != nil {
+			return nil, errors.New("string is not a JSON object")
+		}
+		return obj, nil
+	}
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	if rv.Kind() != reflect.Map && rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not an object", value)
+	}
+	converted, err := ToJSONValue(value)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := converted.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%T is not an object", value)
+	}
+	return obj, nil
+}
+
+func coerceArray(elemType string, value interface{}) (interface{}, error) {
+	var elems []interface{}
+	if s, ok := value.(string); ok {
+		if err := utiljson.Unmarshal([]byte(s), &elems); err != nil {
+			return nil, errors.New("string is not a JSON array")
+		}
+	} else {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return nil, fmt.Errorf("%T is not an array", value)
+		}
+		converted, err := ToJSONValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if converted == nil {
+			// nil slice
+			return []interface{}{}, nil
+		}
+		if elems, ok = converted.([]interface{}); !ok {
+			return nil, fmt.Errorf("%T is not an array", value)
+		}
+	}
+
+	if elemType == "" {
+		return elems, nil
+	}
+	result := make([]interface{}, len(elems))
+	for i, elem := range elems {
+		coerced, err := coerceValue(elemType, elem)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("element %d", i))
+		}
+		result[i] = coerced
+	}
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package structemplate
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCoerceParamValue(t *testing.T) {
+	cases := []struct {
+		dataType string
+		value    interface{}
+		expected interface{}
+	}{
+		{DataTypeInt, 3, int64(3)},
+		{DataTypeInt, float32(8), int64(8)},
+		{DataTypeInt, " 08 ", int64(8)},
+		{DataTypeInt, float64(math.MinInt64), int64(math.MinInt64)},
+		{DataTypeFloat, float32(1.5), float64(1.5)},
+		{DataTypeFloat, "1e3", float64(1000)},
+		{DataTypeBoolean, "true", true},
+		{DataTypeString, 1.5, "1.5"},
+		{DataTypeString, false, "false"},
+		{DataTypeObject, map[string]string{"a": "b"}, map[string]interface{}{"a": "b"}},
+		{DataTypeObject, `{"a": 1}`, map[string]interface{}{"a": int64(1)}},
+		{DataTypeArray, []int{1, 2}, []interface{}{int64(1), int64(2)}},
+		{"array[string]", []interface{}{"a", 1}, []interface{}{"a", "1"}},
+		{"array[int]", `[1, "2"]`, []interface{}{int64(1), int64(2)}},
+	}
+	for i, c := range cases {
+		coerced, err := CoerceParamValue(&TemplateDynamicParam{ParamCode: "P", ValueDataType: c.dataType}, c.value)
+		if err != nil || !reflect.DeepEqual(coerced, c.expected) {
+			t.Logf("Case %d: unexpected result %#v, %v", i, coerced, err)
+			t.FailNow()
+			return
+		}
+	}
+
+	invalid := []struct {
+		dataType string
+		value    interface{}
+	}{
+		{DataTypeInt, 1.5},
+		{DataTypeInt, "abc"},
+		{DataTypeInt, math.Exp2(63)},
+		{DataTypeInt, -math.Exp2(64)},
+		{DataTypeBoolean, 1},
+		{DataTypeString, []string{"a"}},
+		{DataTypeObject, []string{"a"}},
+		{"array[int]", []interface{}{1, "x"}},
+		{"uuid", "x"},
+	}
+	for i, c := range invalid {
+		_, err := CoerceParamValue(&TemplateDynamicParam{ParamCode: "P", ValueDataType: c.dataType}, c.value)
+		if valueErr, ok := err.(*ParamValueError); !ok || valueErr.ParamCode != "P" {
+			t.Logf("Case %d: expected ParamValueError, got %v", i, err)
+			t.FailNow()
+			return
+		}
+	}
+}
+
+func TestValidateParamValues(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "REPLICAS", ValueDataType: DataTypeInt},
+		{ParamCode: "DEBUG", ValueDataType: DataTypeBoolean},
+		{ParamCode: "PORTS", ValueDataType: "array[int]"},
+		{ParamCode: "NAME"},
+	}
+	values, err := ValidateParamValues(params, ParamValuesMap{"REPLICAS": "3", "DEBUG": "false", "NAME": 1})
+	if err != nil || values["REPLICAS"] != int64(3) || values["DEBUG"] != false || values["NAME"] != 1 {
+		t.Logf("Unexpected values: %+v, %v", values, err)
+		t.FailNow()
+		return
+	}
+
+	_, err = ValidateParamValues(params, ParamValuesMap{"REPLICAS": "x", "DEBUG": "no way", "PORTS": []interface{}{80}})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 2 || errs[0].ParamCode != "REPLICAS" || errs[1].ParamCode != "DEBUG" {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestTemplateRender_CoerceValues(t *testing.T) {
+	tmpl := NewTemplate("coerce", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: demo\n", []TemplateDynamicParam{
+		{
+			ParamCode:          "SIZE",
+			ParamType:          ParamTypeJsonPath,
+			ValueDataType:      DataTypeFloat,
+			ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, ParamJsonPath: "data"}},
+			MapKey:             "size",
+		},
+	})
+	objs, err := tmpl.Render(ParamValuesMap{"SIZE": float32(1.5)})
+	if err != nil || objs[0].Object["data"].(map[string]interface{})["size"] != 1.5 {
+		t.Logf("Unexpected result: %+v, %v", objs, err)
+		t.FailNow()
+		return
+	}
+}
@@ -4,6 +4,7 @@ import "log"
 
 // SplitParamsByType splits an array of TemplateDynamicParams into two groups
 // by their type (StrSlot or JsonPath) and builds a params map with ParamCodes as keys.
+// JsonPatch params are applied to decoded objects as JsonPath params and are grouped with them.
 // Returns: StrSlot Params, JsonPath Params, Params Map
 func SplitParamsByType(params []TemplateDynamicParam) ([]*TemplateDynamicParam, []*TemplateDynamicParam, map[string]*TemplateDynamicParam) {
 	strSlotParams := make([]*TemplateDynamicParam, 1)
@@ -13,7 +14,7 @@ func SplitParamsByType(params []TemplateDynamicParam) ([]*TemplateDynamicParam,
 		switch p.ParamType {
 		case ParamTypeStrSlot:
 			strSlotParams = append(strSlotParams, &p)
-		case ParamTypeJsonPath:
+		case ParamTypeJsonPath, ParamTypeJsonPatch:
 			jsonPathParams = append(jsonPathParams, &p)
 		default:
 			log.Println("Unknown Param type: " + p.ParamType)
@@ -24,3 +25,52 @@ func SplitParamsByType(params []TemplateDynamicParam) ([]*TemplateDynamicParam,
 	}
 	return strSlotParams, jsonPathParams, paramsMap
 }
+
+// StrSlotParamsCheckResult is the result of CheckStrSlotParams.
+type StrSlotParamsCheckResult struct {
+	Undefined []StrSlotVariable `json:"undefined"` // 模板中引用但未定义为StrSlot参数的变量
+	Unused    []string          `json:"unused"`    // 已定义但模板中未引用的StrSlot参数ParamCode
+}
+
+// HasProblems reports whether any variable is undefined or any param is unused.
+func (r *StrSlotParamsCheckResult) HasProblems() bool {
+	return len(r.Undefined) > 0 || len(r.Unused) > 0
+}
+
+// CheckStrSlotParams cross-checks the variables referenced in a StrSlot template with the StrSlot params defined.
+// Variables without a StrSlot param are reported as Undefined even if a default value is declared in the template,
+// StrSlot params never referenced by the template are reported as Unused.
+func CheckStrSlotParams(tmpl string, params []TemplateDynamicParam) (*StrSlotParamsCheckResult, error) {
+	return checkStrSlotParams(tmpl, params, StrSlotRenderOptions{})
+}
+
+func checkStrSlotParams(tmpl string, params []TemplateDynamicParam, opts StrSlotRenderOptions) (*StrSlotParamsCheckResult, error) {
+	vars, err := AnalyzeStrSlotTemplateWithOptions(tmpl, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defined := make(map[string]bool)
+	for _, p := range params {
+		if p.ParamType == ParamTypeStrSlot {
+			defined[p.ParamCode] = true
+		}
+	}
+	used := make(map[string]bool, len(vars))
+	result := &StrSlotParamsCheckResult{
+		Undefined: make([]StrSlotVariable, 0),
+		Unused:    make([]string, 0),
+	}
+	for _, v := range vars {
+		used[v.Name] = true
+		if !defined[v.Name] {
+			result.Undefined = append(result.Undefined, v)
+		}
+	}
+	for _, p := range params {
+		if p.ParamType == ParamTypeStrSlot && !used[p.ParamCode] && !containsString(result.Unused, p.ParamCode) {
+			result.Unused = append(result.Unused, p.ParamCode)
+		}
+	}
+	return result, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -179,3 +180,197 @@ func TestJsonpathGetterSetter(t *testing.T) {
 	}
 	t.Logf("After modify: %+v", string(jsonResult))
 }
+
+func TestRenderJsonPathParams_LabelSelector(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	newDeploy := func(name string, app string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName(name)
+		obj.SetLabels(map[string]string{"app": app})
+		return obj
+	}
+	objsMap := map[schema.GroupVersionKind][]*unstructured.Unstructured{
+		gvk: {newDeploy("frontend", "frontend"), newDeploy("backend", "backend")},
+	}
+
+	replicasParam := TemplateDynamicParam{
+		ParamCode: "BACKEND_REPLICAS",
+		ParamType: ParamTypeJsonPath,
+		ValueInjectTargets: []JsonPathParamTarget{
+			{
+				TargetGVK:           gvk,
+				ParamJsonPath:       ".spec.replicas",
+				ObjectLabelSelector: map[string]string{"app": "backend"},
+			},
+		},
+	}
+	err := RenderJsonPathParams(objsMap, []TemplateDynamicParam{replicasParam}, map[string]interface{}{"BACKEND_REPLICAS": int64(3)})
+	if err != nil {
+		t.Logf("Failed render JsonPath params: %+v", err)
+		t.FailNow()
+		return
+	}
+	if _, exists := objsMap[gvk][0].Object["spec"]; exists {
+		t.Log("Object not matching the label selector is modified")
+		t.FailNow()
+		return
+	}
+	if v, _ := GetValueOfNestedField(objsMap[gvk][1].Object, ".spec.replicas"); v != int64(3) {
+		t.Logf("Unexpected replicas of the selected object: %v", v)
+		t.FailNow()
+		return
+	}
+
+	// a required param matching no object should fail
+	replicasParam.ValueInjectTargets[0].ObjectLabelSelector = map[string]string{"app": "missing"}
+	err = RenderJsonPathParams(objsMap, []TemplateDynamicParam{replicasParam}, map[string]interface{}{"BACKEND_REPLICAS": int64(3)})
+	if err == nil {
+		t.Log("Expected error does not occurred")
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderJsonpathParam_Remove(t *testing.T) {
+	obj := parseObject(t)
+	unstructuredObj := unstructured.Unstructured{Object: obj}
+	removeParam := TemplateDynamicParam{
+		ParamCode:  "DROP_HOSTNAME",
+		ParamType:  ParamTypeJsonPath,
+		InjectMode: InjectModeRemove,
+		ValueInjectTargets: []JsonPathParamTarget{
+			{ParamJsonPath: `.spec.hostnames[?(@=="hostname1.example.com")]`},
+			{ParamJsonPath: ".spec.parentRefs[0].port"},
+		},
+	}
+
+	// false value skips the removal
+	err := RenderJsonPathParamForUnstructuredObj(&unstructuredObj, &removeParam, &removeParam.ValueInjectTargets[1], false)
+	if v, _ := GetValueOfNestedField(obj, ".spec.parentRefs[0].port"); err != nil || v == nil {
+		t.Logf("Field removed by a false value: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	for i := range removeParam.ValueInjectTargets {
+		if err := RenderJsonPathParamForUnstructuredObj(&unstructuredObj, &removeParam, &removeParam.ValueInjectTargets[i], true); err != nil {
+			t.Logf("Failed remove field: %+v", err)
+			t.FailNow()
+			return
+		}
+	}
+	hostnames, _ := GetValueOfNestedField(obj, ".spec.hostnames")
+	if arr := hostnames.([]interface{}); len(arr) != 1 || arr[0] != "hostname2.example.com" {
+		t.Logf("Unexpected hostnames after removal: %v", hostnames)
+		t.FailNow()
+		return
+	}
+	if _, exists := obj["spec"].(map[string]interface{})["parentRefs"].([]interface{})[0].(map[string]interface{})["port"]; exists {
+		t.Log("Field is not removed")
+		t.FailNow()
+		return
+	}
+
+	// removing again fails unless IgnoreMissing is set
+	if err := RenderJsonPathParamForUnstructuredObj(&unstructuredObj, &removeParam, &removeParam.ValueInjectTargets[1], true); err == nil {
+		t.Log("Expected error does not occurred")
+		t.FailNow()
+		return
+	}
+	removeParam.IgnoreMissing = true
+	removeParam.ValueInjectTargets[1].ParamJsonPath = ".spec.missing.port"
+	if err := RenderJsonPathParamForUnstructuredObj(&unstructuredObj, &removeParam, &removeParam.ValueInjectTargets[1], true); err != nil {
+		t.Logf("Unexpected error with IgnoreMissing: %+v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderJsonPathParams_ErrorNotLost(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetLabels(map[string]string{"app": "backend"})
+	objsMap := map[schema.GroupVersionKind][]*unstructured.Unstructured{gvk: {obj}}
+
+	params := []TemplateDynamicParam{
+		{
+			ParamCode: "FRONTEND_REPLICAS",
+			ParamType: ParamTypeJsonPath,
+			ValueInjectTargets: []JsonPathParamTarget{
+				{TargetGVK: gvk, ParamJsonPath: ".spec.replicas", ObjectLabelSelector: map[string]string{"app": "frontend"}},
+			},
+		},
+		{
+			ParamCode: "BACKEND_REPLICAS",
+			ParamType: ParamTypeJsonPath,
+			ValueInjectTargets: []JsonPathParamTarget{
+				{TargetGVK: gvk, ParamJsonPath: ".spec.replicas", ObjectLabelSelector: map[string]string{"app": "backend"}},
+			},
+		},
+	}
+	// the first param matches no object, the error must not be hidden by the second param succeeding
+	err := RenderJsonPathParams(objsMap, params, map[string]interface{}{"FRONTEND_REPLICAS": int64(2), "BACKEND_REPLICAS": int64(3)})
+	pe, ok := err.(*ParamValueError)
+	if !ok || pe.Code != ErrCodeTargetNotMatched || pe.ParamCode != "FRONTEND_REPLICAS" {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderJsonPathParams_AppendArrayElements(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: parseObject(t)}
+	gvk := schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Kind: "TLSRoute"}
+	objsMap := map[schema.GroupVersionKind][]*unstructured.Unstructured{gvk: {obj}}
+	// other tests modify the targets of the shared param
+	param := param
+	param.ValueInjectTargets = []JsonPathParamTarget{{TargetGVK: gvk, ParamJsonPath: ".spec.hostnames"}}
+
+	// ValueDataType is the element type of appended values, both a slice and a single element are accepted
+	values := []interface{}{[]string{"a.example.com", "b.example.com"}, "c.example.com"}
+	for _, value := range values {
+		if err := RenderJsonPathParams(objsMap, []TemplateDynamicParam{param}, map[string]interface{}{param.ParamCode: value}); err != nil {
+			t.Logf("Failed render JsonPath params: %+v", err)
+			t.FailNow()
+			return
+		}
+	}
+	hostnames, _ := GetValueOfNestedField(obj.Object, ".spec.hostnames")
+	if arr := hostnames.([]interface{}); len(arr) != 5 || arr[2] != "a.example.com" || arr[4] != "c.example.com" {
+		t.Logf("Unexpected hostnames: %v", hostnames)
+		t.FailNow()
+		return
+	}
+
+	err := RenderJsonPathParams(objsMap, []TemplateDynamicParam{param}, map[string]interface{}{param.ParamCode: []interface{}{"d.example.com", map[string]interface{}{}}})
+	if pe, ok := err.(*ParamValueError); !ok || pe.Code != ErrCodeInvalidType {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderJsonPathParams_FilterNotMatched(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1alpha2", Kind: "TLSRoute"}
+	objsMap := map[schema.GroupVersionKind][]*unstructured.Unstructured{gvk: {{Object: parseObject(t)}}}
+	portParam := TemplateDynamicParam{
+		ParamCode:          "GATEWAY_PORT",
+		ParamType:          ParamTypeJsonPath,
+		ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: gvk, ParamJsonPath: ".spec.parentRefs[name=missing].port"}},
+	}
+	err := RenderJsonPathParams(objsMap, []TemplateDynamicParam{portParam}, map[string]interface{}{"GATEWAY_PORT": int64(443)})
+	if errors.Cause(err) != ErrPathNotMatched {
+		t.Logf("Unexpected error: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	portParam.Optional = true
+	if err := RenderJsonPathParams(objsMap, []TemplateDynamicParam{portParam}, map[string]interface{}{"GATEWAY_PORT": int64(443)}); err != nil {
+		t.Logf("Optional param should be skipped: %+v", err)
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,276 @@
+package structemplate
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// strategicMergeKeys maps the field path of a list to the key identifying its elements, the same way
+// the `patchMergeKey` tags of Kubernetes types do. Field paths are matched by their longest suffix
+// of field names, e.g. `spec.template.spec.containers.ports` matches `containers.ports`.
+var strategicMergeKeys = map[string]string{
+	"containers":                "name",
+	"initContainers":            "name",
+	"ephemeralContainers":       "name",
+	"containers.ports":          "containerPort",
+	"initContainers.ports":      "containerPort",
+	"ephemeralContainers.ports": "containerPort",
+	"env":                       "name",
+	"volumeMounts":              "mountPath",
+	"volumeDevices":             "devicePath",
+	"volumes":                   "name",
+	"imagePullSecrets":          "name",
+	"hostAliases":               "ip",
+	"topologySpreadConstraints": "topologyKey",
+	"resourceClaims":            "name",
+	"schedulingGates":           "name",
+	"readinessGates":            "conditionType",
+	"conditions":                "type",
+	"ownerReferences":           "uid",
+	"spec.ports":                "port",
+	"volumeClaimTemplates":      "metadata.name",
+}
+
+var strategicMergeKeysLock sync.RWMutex
+
+// RegisterStrategicMergeKey registers the key identifying elements of the list at fieldPath, used by the
+// StrategicMerge inject mode. fieldPath is a '.' separated suffix of field names without array indexes,
+// e.g. `spec.listeners` for a CRD. An empty mergeKey makes the list replaced as a whole.
+func RegisterStrategicMergeKey(fieldPath string, mergeKey string) {
+	strategicMergeKeysLock.Lock()
+	defer strategicMergeKeysLock.Unlock()
+	strategicMergeKeys[strings.Trim(fieldPath, ".")] = mergeKey
+}
+
+// lookupStrategicMergeKey finds the merge key of a list by the longest matched suffix of its field names.
+func lookupStrategicMergeKey(fieldNames []string) (string, bool) {
+	strategicMergeKeysLock.RLock()
+	defer strategicMergeKeysLock.RUnlock()
+	for i := 0; i < len(fieldNames); i++ {
+		if key, ok := strategicMergeKeys[strings.Join(fieldNames[i:], ".")]; ok {
+			return key, len(key) > 0
+		}
+	}
+	return "", false
+}
+
+// Directives of strategic merge patches, set as the `$patch` key of an object.
+const (
+	patchDirectiveKey     = "$patch"
+	patchDirectiveMerge   = "merge"   // merge the object, the default
+	patchDirectiveReplace = "replace" // replace the object or, as the only key of a list element, the whole list
+	patchDirectiveDelete  = "delete"  // delete the object or the list element with the same merge key
+)
+
+// StrategicMerge merges patch into target like a Kubernetes strategic merge patch.
+// fieldNames are the names of the fields leading to target, used to look up the merge keys of lists.
+// Objects are merged recursively and null values delete keys. Elements of lists with a known merge key
+// are merged into the existing element with the same key or appended. Other lists are replaced.
+// The `$patch` key of an object is a directive and never copied: `replace` replaces the object instead of
+// merging it, `delete` deletes it, `merge` is the default, and a list element `{"$patch": "replace"}` replaces
+// the whole list with the other elements. Other directives are rejected.
+// A nil result means that the object is deleted.
+func StrategicMerge(target interface{}, patch interface{}, fieldNames []string) (interface{}, error) {
+	switch p := patch.(type) {
+	case map[string]interface{}:
+		directive, err := patchDirective(p)
+		if err != nil {
+			return nil, err
+		}
+		targetObj, ok := target.(map[string]interface{})
+		switch {
+		case directive == patchDirectiveDelete:
+			return nil, nil
+		case directive == patchDirectiveReplace || !ok:
+			targetObj = make(map[string]interface{}, len(p))
+		}
+		for k, v := range p {
+			if k == patchDirectiveKey {
+				continue
+			}
+			if v == nil {
+				delete(targetObj, k)
+				continue
+			}
+			merged, err := StrategicMerge(targetObj[k], v, appendFieldName(fieldNames, k))
+			if err != nil {
+				return nil, err
+			}
+			if merged == nil {
+				delete(targetObj, k)
+				continue
+			}
+			targetObj[k] = merged
+		}
+		return targetObj, nil
+	case []interface{}:
+		targetArr, isArr := target.([]interface{})
+		mergeKey, ok := lookupStrategicMergeKey(fieldNames)
+		if !ok || !isArr || containsListReplaceDirective(p) {
+			return replaceListElements(p, fieldNames)
+		}
+		keyPath, err := compileJsonPathCached(mergeKey)
+		if err != nil {
+			return replaceListElements(p, fieldNames)
+		}
+		return mergeListByKey(targetArr, p, keyPath, fieldNames)
+	default:
+		return DeepCopyJSONValue(patch), nil
+	}
+}
+
+// patchDirective returns the `$patch` directive of a patch object, "" when it has none.
+func patchDirective(patchObj map[string]interface{}) (string, error) {
+	value, ok := patchObj[patchDirectiveKey]
+	if !ok {
+		return "", nil
+	}
+	directive, _ := value.(string)
+	switch directive {
+	case patchDirectiveMerge, patchDirectiveReplace, patchDirectiveDelete:
+		return directive, nil
+	}
+	return "", fmt.Errorf("unsupported %s directive: %v", patchDirectiveKey, value)
+}
+
+func mergeListByKey(target []interface{}, patch []interface{}, keyPath *JsonPath, fieldNames []string) ([]interface{}, error) {
+	result := make([]interface{}, len(target))
+	copy(result, target)
+
+	for _, patchElem := range patch {
+		patchObj, ok := patchElem.(map[string]interface{})
+		patchKey, keyErr := keyPath.Get(patchObj)
+		if !ok || keyErr != nil || patchKey == nil {
+			// elements without the merge key are appended
+			elems, err := replaceListElements([]interface{}{patchElem}, fieldNames)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, elems...)
+			continue
+		}
+
+		idx := -1
+		for i, elem := range result {
+			if elemObj, ok := elem.(map[string]interface{}); ok {
+				if elemKey, err := keyPath.Get(elemObj); err == nil && jsonValueEqual(elemKey, patchKey) {
+					idx = i
+					break
+				}
+			}
+		}
+		var current interface{}
+		if idx > -1 {
+			current = result[idx]
+		}
+		merged, err := StrategicMerge(current, patchObj, fieldNames)
+		switch {
+		case err != nil:
+			return nil, err
+		case merged == nil && idx > -1:
+			result = append(result[:idx], result[idx+1:]...)
+		case merged == nil:
+		case idx > -1:
+			result[idx] = merged
+		default:
+			result = append(result, merged)
+		}
+	}
+	return result, nil
+}
+
+// replaceListElements returns the elements of a list replacing the existing one, without the directives:
+// the objects are copied without `$patch` key, the deleted ones and `{"$patch": "replace"}` are dropped.
+func replaceListElements(patch []interface{}, fieldNames []string) ([]interface{}, error) {
+	result := make([]interface{}, 0, len(patch))
+	for _, elem := range patch {
+		if isListReplaceDirective(elem) {
+			continue
+		}
+		if _, isObj := elem.(map[string]interface{}); !isObj {
+			result = append(result, DeepCopyJSONValue(elem))
+			continue
+		}
+		merged, err := StrategicMerge(nil, elem, fieldNames)
+		if err != nil {
+			return nil, err
+		}
+		if merged != nil {
+			result = append(result, merged)
+		}
+	}
+	return result, nil
+}
+
+// isListReplaceDirective reports whether a list element is `{"$patch": "replace"}`.
+func isListReplaceDirective(elem interface{}) bool {
+	elemObj, ok := elem.(map[string]interface{})
+	return ok && len(elemObj) == 1 && elemObj[patchDirectiveKey] == patchDirectiveReplace
+}
+
+func containsListReplaceDirective(patch []interface{}) bool {
+	for _, elem := range patch {
+		if isListReplaceDirective(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendFieldName(fieldNames []string, name string) []string {
+	names := make([]string, len(fieldNames), len(fieldNames)+1)
+	copy(names, fieldNames)
+	return append(names, name)
+}
+
+// StrategicMerge merges patch into every node located by the path like a Kubernetes strategic merge patch.
+// When the located node is a list with a known merge key and patch is a single object,
+// the object is merged as one element of the list.
+// An error caused by ErrPathNotMatched is returned when a multi-match path matches nothing.
+func (p *JsonPath) StrategicMerge(object map[string]interface{}, patch interface{}) error {
+	patch, err := ToJSONValue(patch)
+	if err != nil {
+		return err
+	}
+	refs, err := p.resolveTargets(object)
+	if err != nil {
+		return err
+	}
+	directive := ""
+	if patchObj, isObj := patch.(map[string]interface{}); isObj {
+		if directive, err = patchDirective(patchObj); err != nil {
+			return err
+		}
+	}
+	for _, ref := range refs {
+		current, _ := ref.get()
+		fieldNames := ref.fieldNames()
+		if _, isObj := patch.(map[string]interface{}); isObj {
+			if _, isArr := current.([]interface{}); isArr {
+				if _, ok := lookupStrategicMergeKey(fieldNames); ok {
+					merged, err := StrategicMerge(current, []interface{}{patch}, fieldNames)
+					if err != nil {
+						return err
+					}
+					ref.set(merged)
+					continue
+				}
+			}
+			// the located node can be replaced but not deleted, see InjectModeRemove
+			if directive == patchDirectiveDelete || ref.isRoot && directive == patchDirectiveReplace {
+				return fmt.Errorf("%s: %s cannot be used on the located node %s", patchDirectiveKey, directive, p.String())
+			}
+		} else if ref.isRoot {
+			return errors.New("only an object can be merged into the root object")
+		}
+		merged, err := StrategicMerge(current, patch, fieldNames)
+		if err != nil {
+			return err
+		}
+		ref.set(merged)
+	}
+	return nil
+}
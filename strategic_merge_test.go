@@ -0,0 +1,168 @@
+package structemplate
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRenderJsonpathParam_StrategicMerge(t *testing.T) {
+	obj := parseWorkload(t)
+	unstructuredObj := unstructured.Unstructured{Object: obj}
+	mergeParam := TemplateDynamicParam{
+		ParamCode:  "CONTAINERS",
+		ParamType:  ParamTypeJsonPath,
+		InjectMode: InjectModeStrategicMerge,
+		ValueInjectTargets: []JsonPathParamTarget{
+			{ParamJsonPath: "spec.template.spec.containers"},
+			{ParamJsonPath: "spec.template.spec"},
+		},
+	}
+
+	// a single element replaces the element with the same name
+	err := RenderJsonPathParamForUnstructuredObj(&unstructuredObj, &mergeParam, &mergeParam.ValueInjectTargets[0], map[string]interface{}{
+		"name":  "app",
+		"image": "app:v2",
+		"env":   []interface{}{map[string]interface{}{"name": "MODE", "value": "prod"}},
+	})
+	if err != nil {
+		t.Logf("Failed strategic merge: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	err = RenderJsonPathParamForUnstructuredObj(&unstructuredObj, &mergeParam, &mergeParam.ValueInjectTargets[1], map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{
+				"name": "app",
+				"env": []map[string]string{
+					{"name": "MODE", "value": "dev"},
+					{"name": "DEBUG", "value": "true"},
+				},
+			},
+			map[string]interface{}{"name": "sidecar", "$patch": "delete"},
+			map[string]interface{}{"name": "proxy", "image": "proxy:v1"},
+		},
+	})
+	if err != nil {
+		t.Logf("Failed strategic merge: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	containers, _ := GetValueOfNestedField(obj, "spec.template.spec.containers")
+	expected := []interface{}{
+		map[string]interface{}{
+			"name":      "app",
+			"image":     "app:v2",
+			"resources": map[string]interface{}{"limits": map[string]interface{}{"cpu": "1"}},
+			"env": []interface{}{
+				map[string]interface{}{"name": "MODE", "value": "dev"},
+				map[string]interface{}{"name": "DEBUG", "value": "true"},
+			},
+		},
+		map[string]interface{}{"name": "proxy", "image": "proxy:v1"},
+	}
+	if !jsonValueEqual(containers, expected) {
+		t.Logf("Unexpected containers after strategic merge: %v", containers)
+		t.FailNow()
+		return
+	}
+}
+
+func TestStrategicMergeCustomKey(t *testing.T) {
+	RegisterStrategicMergeKey("spec.listeners", "name")
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"listeners": []interface{}{map[string]interface{}{"name": "http", "port": int64(80)}},
+			"addresses": []interface{}{"10.0.0.1"},
+		},
+	}
+	err := StrategicMergeNestedField(obj, "spec", map[string]interface{}{
+		"listeners": []interface{}{map[string]interface{}{"name": "http", "port": int64(8080)}},
+		"addresses": []interface{}{"10.0.0.2"},
+	})
+	if err != nil {
+		t.Logf("Failed strategic merge: %+v", err)
+		t.FailNow()
+		return
+	}
+	listeners, _ := GetValueOfNestedField(obj, "spec.listeners")
+	addresses, _ := GetValueOfNestedField(obj, "spec.addresses")
+	if len(listeners.([]interface{})) != 1 || !jsonValueEqual(addresses, []interface{}{"10.0.0.2"}) {
+		t.Logf("Unexpected result: %v %v", listeners, addresses)
+		t.FailNow()
+		return
+	}
+}
+
+func TestStrategicMergeDirectives(t *testing.T) {
+	newObj := func() map[string]interface{} {
+		return map[string]interface{}{
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "app:v1", "args": []interface{}{"--v"}},
+					map[string]interface{}{"name": "sidecar", "image": "sidecar:v1"},
+				},
+				"resources":    map[string]interface{}{"limits": map[string]interface{}{"cpu": "1"}, "requests": map[string]interface{}{"cpu": "1"}},
+				"nodeSelector": map[string]interface{}{"zone": "a"},
+			},
+		}
+	}
+
+	// replace an element and an object, merge explicitly and delete an object, the directives are never copied
+	obj := newObj()
+	err := StrategicMergeNestedField(obj, "spec", map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "app", "image": "app:v2", "$patch": "replace"},
+			map[string]interface{}{"name": "sidecar", "image": "sidecar:v2", "$patch": "merge"},
+			map[string]interface{}{"name": "proxy", "image": "proxy:v1", "$patch": "merge"},
+		},
+		"resources":    map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}, "$patch": "replace"},
+		"nodeSelector": map[string]interface{}{"$patch": "delete"},
+	})
+	if err != nil {
+		t.Logf("Failed strategic merge: %+v", err)
+		t.FailNow()
+		return
+	}
+	expected := map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "app", "image": "app:v2"},
+			map[string]interface{}{"name": "sidecar", "image": "sidecar:v2"},
+			map[string]interface{}{"name": "proxy", "image": "proxy:v1"},
+		},
+		"resources": map[string]interface{}{"limits": map[string]interface{}{"memory": "1Gi"}},
+	}
+	if !jsonValueEqual(obj["spec"], expected) {
+		t.Logf("Unexpected result: %v", obj["spec"])
+		t.FailNow()
+		return
+	}
+
+	// `{"$patch": "replace"}` replaces the whole list
+	obj = newObj()
+	err = StrategicMergeNestedField(obj, "spec.containers", []interface{}{
+		map[string]interface{}{"$patch": "replace"},
+		map[string]interface{}{"name": "only", "image": "only:v1"},
+	})
+	containers, _ := GetValueOfNestedField(obj, "spec.containers")
+	if err != nil || !jsonValueEqual(containers, []interface{}{map[string]interface{}{"name": "only", "image": "only:v1"}}) {
+		t.Logf("Unexpected result of list replace: %v, %+v", containers, err)
+		t.FailNow()
+		return
+	}
+
+	// unknown directives and deleting the located node are rejected
+	for path, patch := range map[string]interface{}{
+		"spec.containers": map[string]interface{}{"name": "app", "$patch": "retainKeys"},
+		"spec.resources":  map[string]interface{}{"$patch": "delete"},
+		"":                map[string]interface{}{"$patch": "replace"},
+	} {
+		if err := StrategicMergeNestedField(newObj(), path, patch); err == nil {
+			t.Logf("Expected error of directive %v at %q does not occurred", patch, path)
+			t.FailNow()
+			return
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package structemplate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultStrSlotLiteralEscape is the escape sequence of literal placeholders used with custom delimiters
+// when StrSlotRenderOptions.LiteralEscape is empty, e.g. `\[[VAR]]` renders `[[VAR]]`.
+const DefaultStrSlotLiteralEscape = "\\"
+
+// strSlotSyntax converts templates using custom delimiters to the `${...}` form understood by envsubst.
+type strSlotSyntax struct {
+	left    string
+	right   string
+	escape  string
+	keep    bool   // keep the escape sequences of literal delimiters for a later rendering pass
+	tmpl    string // the original template
+	offsets []int  // original byte offset of every byte of the converted template, plus the end
+}
+
+// newStrSlotSyntax returns nil for the default `${...}` syntax.
+func newStrSlotSyntax(opts StrSlotRenderOptions) (*strSlotSyntax, error) {
+	if opts.LeftDelim == "" && opts.RightDelim == "" || opts.LeftDelim == "${" && opts.RightDelim == "}" {
+		return nil, nil
+	}
+	if opts.LeftDelim == "" || opts.RightDelim == "" {
+		return nil, fmt.Errorf("both delimiters must be set, got %q and %q", opts.LeftDelim, opts.RightDelim)
+	}
+	escape := opts.LiteralEscape
+	if escape == "" {
+		escape = DefaultStrSlotLiteralEscape
+	}
+	return &strSlotSyntax{left: opts.LeftDelim, right: opts.RightDelim, escape: escape, keep: opts.MissingKeyMode == StrSlotMissingKeyKeep}, nil
+}
+
+// convert translates tmpl to the `${...}` form. Every '$' outside the placeholders is doubled so that
+// existing `${...}` and `$$` are rendered as they are. Spaces inside the delimiters are trimmed, `[[ NAME ]]` is `${NAME}`.
+func (s *strSlotSyntax) convert(tmpl string) (string, error) {
+	s.tmpl = tmpl
+	s.offsets = make([]int, 0, len(tmpl)+8)
+	b := strings.Builder{}
+	emit := func(str string, origOffset int) {
+		b.WriteString(str)
+		for j := 0; j < len(str); j++ {
+			s.offsets = append(s.offsets, origOffset)
+		}
+	}
+
+	depth := 0
+	opened := make([]int, 0)
+	for i := 0; i < len(tmpl); {
+		switch {
+		case strings.HasPrefix(tmpl[i:], s.escape+s.left):
+			// literal left delimiter, escaped again in StrSlotMissingKeyKeep mode
+			if s.keep {
+				emit(strings.ReplaceAll(s.escape, "$", "$$"), i)
+			}
+			i += len(s.escape)
+			emit(strings.ReplaceAll(s.left, "$", "$$"), i)
+			i += len(s.left)
+			if s.left == s.right && depth == 0 {
+				// symmetric delimiters, the matching right delimiter of `\@@VAR@@` is literal as well
+				i = s.skipLiteral(tmpl, i, emit)
+			}
+		case depth > 0 && isBlank(tmpl[i]) && strings.HasPrefix(strings.TrimLeft(tmpl[i:], " \t"), s.right):
+			// spaces before the right delimiter, `[[ NAME ]]`
+			i = len(tmpl) - len(strings.TrimLeft(tmpl[i:], " \t"))
+		case depth > 0 && strings.HasPrefix(tmpl[i:], s.right):
+			emit("}", i)
+			i += len(s.right)
+			depth--
+			opened = opened[:len(opened)-1]
+		case strings.HasPrefix(tmpl[i:], s.left) && (s.left != s.right || depth == 0):
+			emit("$", i)
+			emit("{", i+1)
+			opened = append(opened, i)
+			i += len(s.left)
+			depth++
+			// spaces after the left delimiter
+			for i < len(tmpl) && isBlank(tmpl[i]) {
+				i++
+			}
+		case tmpl[i] == '$' && depth == 0:
+			emit("$$", i)
+			i++
+		default:
+			emit(tmpl[i:i+1], i)
+			i++
+		}
+	}
+	if depth > 0 {
+		line, column := positionOf(tmpl, opened[len(opened)-1])
+		return "", fmt.Errorf("missing closing delimiter %q of the placeholder at line %d, column %d", s.right, line, column)
+	}
+	s.offsets = append(s.offsets, len(tmpl))
+	return b.String(), nil
+}
+
+// skipLiteral emits the text of a literal placeholder started at i through its right delimiter,
+// which may be escaped as well, and returns the offset after it.
+func (s *strSlotSyntax) skipLiteral(tmpl string, i int, emit func(string, int)) int {
+	end := strings.Index(tmpl[i:], s.right)
+	if end < 0 {
+		return i
+	}
+	end += i
+	text := tmpl[i:end]
+	escaped := strings.HasSuffix(text, s.escape)
+	if escaped {
+		text = text[:len(text)-len(s.escape)]
+	}
+	for j := 0; j < len(text); j++ {
+		if text[j] == '$' {
+			emit("$$", i+j)
+		} else {
+			emit(text[j:j+1], i+j)
+		}
+	}
+	if escaped && s.keep {
+		emit(strings.ReplaceAll(s.escape, "$", "$$"), i+len(text))
+	}
+	emit(strings.ReplaceAll(s.right, "$", "$$"), end)
+	return end + len(s.right)
+}
+
+// original maps a placeholder found in the converted template back to the original template.
+func (s *strSlotSyntax) original(slot StrSlot) StrSlot {
+	if s == nil {
+		return slot
+	}
+	converted := slot
+	slot.Offset = s.offsets[converted.Offset]
+	slot.Raw = s.tmpl[slot.Offset:s.offsets[converted.Offset+len(converted.Raw)]]
+	slot.Line, slot.Column = positionOf(s.tmpl, slot.Offset)
+	if converted.Args != "" {
+		argsStart := converted.Offset + len("${") + len(converted.Name) + len(converted.Operator)
+		// the end maps to the right delimiter, after the trimmed spaces
+		slot.Args = strings.TrimRight(s.tmpl[s.offsets[argsStart]:s.offsets[argsStart+len(converted.Args)]], " \t")
+	}
+	if len(converted.Nested) > 0 {
+		slot.Nested = s.originalSlots(converted.Nested)
+	}
+	return slot
+}
+
+func (s *strSlotSyntax) originalSlots(slots []StrSlot) []StrSlot {
+	if s == nil {
+		return slots
+	}
+	result := make([]StrSlot, 0, len(slots))
+	for _, slot := range slots {
+		result = append(result, s.original(slot))
+	}
+	return result
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// positionOf returns the 1-based line and column of the byte offset in tmpl.
+func positionOf(tmpl string, offset int) (int, int) {
+	line, column := 1, 1
+	for _, r := range tmpl[:offset] {
+		line, column = advancePosition(r, line, column)
+	}
+	return line, column
+}
@@ -0,0 +1,116 @@
+package structemplate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderStrSlotTemplate_Delimiters(t *testing.T) {
+	tmpl := `#!/bin/sh
+echo "${HOME} $$ [[NAME]]"
+image: [[REGISTRY:-docker.io]]/[[IMAGE | lower]]
+literal: \[[NAME]]
+owner: [[OWNER:-[[TEAM]]]]
+`
+	values := map[string]interface{}{"NAME": "demo", "IMAGE": "App", "TEAM": "ops"}
+	result, _, err := RenderStrSlotTemplateWithOptions(tmpl, values, nil, StrSlotRenderOptions{LeftDelim: "[[", RightDelim: "]]"})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	expected := `#!/bin/sh
+echo "${HOME} $$ demo"
+image: docker.io/app
+literal: [[NAME]]
+owner: ops
+`
+	if result != expected {
+		t.Logf("Unexpected result: %s", result)
+		t.FailNow()
+		return
+	}
+
+	result, _, err = RenderStrSlotTemplateWithOptions("a: @@A@@-${B}-%%@@A%%@@\n", map[string]interface{}{"A": "x"}, nil,
+		StrSlotRenderOptions{LeftDelim: "@@", RightDelim: "@@", LiteralEscape: "%%"})
+	if err != nil || result != "a: x-${B}-@@A@@\n" {
+		t.Logf("Unexpected result: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+
+	// symmetric delimiters with the default escape
+	opts := StrSlotRenderOptions{LeftDelim: "@@", RightDelim: "@@"}
+	result, _, err = RenderStrSlotTemplateWithOptions("a: @@X@@ and @@ Y @@\n", map[string]interface{}{"X": "x", "Y": "y"}, nil, opts)
+	if err != nil || result != "a: x and y\n" {
+		t.Logf("Unexpected result: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+	result, _, err = RenderStrSlotTemplateWithOptions("a: \\@@VAR@@ and @@X@@ $$\n", map[string]interface{}{"X": "x", "VAR": "v"}, nil, opts)
+	if err != nil || result != "a: @@VAR@@ and x $$\n" {
+		t.Logf("Unexpected result: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+	opts.MissingKeyMode = StrSlotMissingKeyKeep
+	result, _, err = RenderStrSlotTemplateWithOptions("a: \\@@VAR@@ and @@X@@ @@Y@@\n", map[string]interface{}{"X": "x"}, nil, opts)
+	if err != nil || result != "a: \\@@VAR@@ and x @@Y@@\n" {
+		t.Logf("Unexpected result in Keep mode: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderStrSlotTemplate_DelimitersPositions(t *testing.T) {
+	tmpl := "name: {{NAME}}\nimage: ${X} {{IMAGE:?required}}\n"
+	opts := StrSlotRenderOptions{LeftDelim: "{{", RightDelim: "}}", MissingKeyMode: StrSlotMissingKeyError}
+	_, _, err := RenderStrSlotTemplateWithOptions(tmpl, map[string]interface{}{"NAME": "demo"}, nil, opts)
+	missingErr, ok := err.(*MissingStrSlotError)
+	if !ok || len(missingErr.Slots) != 1 || missingErr.Slots[0].Raw != "{{IMAGE:?required}}" || missingErr.Slots[0].Position() != "2:13" {
+		t.Logf("Unexpected error: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	opts.MissingKeyMode = StrSlotMissingKeyKeep
+	result, _, err := RenderStrSlotTemplateWithOptions(tmpl, map[string]interface{}{"NAME": "demo"}, nil, opts)
+	if err != nil || result != "name: demo\nimage: ${X} {{IMAGE:?required}}\n" {
+		t.Logf("Unexpected result: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+
+	vars, err := AnalyzeStrSlotTemplateWithOptions(tmpl, opts)
+	if err != nil || len(vars) != 2 || vars[1].Name != "IMAGE" || vars[1].Slots[0].Args != "required" || vars[1].Slots[0].Offset != strings.Index(tmpl, "{{IMAGE") {
+		t.Logf("Unexpected variables: %+v, %+v", vars, err)
+		t.FailNow()
+		return
+	}
+
+	if _, _, err := RenderStrSlotTemplateWithOptions("name: {{NAME\n", nil, nil, opts); err == nil {
+		t.Logf("Unclosed placeholder should fail")
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderStrSlotTemplate_DelimitersNonASCII(t *testing.T) {
+	tmpl := "név: « NAME » \\«NAME»\nimage: «IMAGE:?required »\n"
+	opts := StrSlotRenderOptions{LeftDelim: "«", RightDelim: "»", MissingKeyMode: StrSlotMissingKeyError}
+	_, _, err := RenderStrSlotTemplateWithOptions(tmpl, map[string]interface{}{"NAME": "demo"}, nil, opts)
+	missingErr, ok := err.(*MissingStrSlotError)
+	if !ok || len(missingErr.Slots) != 1 || missingErr.Slots[0].Raw != "«IMAGE:?required »" || missingErr.Slots[0].Position() != "2:8" ||
+		missingErr.Slots[0].Args != "required" {
+		t.Logf("Unexpected error: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	result, _, err := RenderStrSlotTemplateWithOptions(tmpl, map[string]interface{}{"NAME": "demo", "IMAGE": "app"}, nil, opts)
+	if err != nil || result != "név: demo «NAME»\nimage: app\n" {
+		t.Logf("Unexpected result: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,384 @@
+package structemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// Escaping of StrSlot values
+const (
+	// StrSlotEscapeNone inserts the values as they are, the default behaviour.
+	StrSlotEscapeNone = ""
+	// StrSlotEscapeYAML escapes the values according to the yaml context of the placeholders.
+	// JSON documents are handled as yaml flow collections.
+	StrSlotEscapeYAML = "YAML"
+)
+
+// yaml contexts of a placeholder
+const (
+	slotContextPlain        = "plain scalar"
+	slotContextDoubleQuoted = "double-quoted scalar"
+	slotContextSingleQuoted = "single-quoted scalar"
+	slotContextBlock        = "block scalar"
+	slotContextComment      = "comment"
+)
+
+// slotContext describes where a placeholder sits in a yaml/json document.
+type slotContext struct {
+	kind    string
+	flow    bool   // inside a flow collection `[]` or `{}`
+	atStart bool   // the placeholder starts a plain scalar
+	atEnd   bool   // the placeholder ends a plain scalar
+	key     bool   // the plain scalar is a mapping key
+	column  int    // byte column of the placeholder in its line, from 0
+	indent  string // leading whitespace of the line containing the placeholder
+}
+
+// UnsafeStrSlotError is returned when a value cannot be safely inserted at the position of a placeholder.
+type UnsafeStrSlotError struct {
+	Slot    StrSlot
+	Context string // yaml context of the placeholder
+	Reason  string
+}
+
+func (e *UnsafeStrSlotError) Error() string {
+	return fmt.Sprintf("value of StrSlot variable %s (line %d, column %d) cannot be safely escaped in a %s: %s",
+		e.Slot.Name, e.Slot.Line, e.Slot.Column, e.Context, e.Reason)
+}
+
+// escapeSlotValue escapes value for the context of the placeholder.
+// prev and next are the bytes around the placeholder in the rendered output, ' ' for line breaks.
+// typed is the param value rendered as value after the filters, nil for other text. When the placeholder is
+// a whole plain scalar, a string is quoted if it would not be loaded as the same string, e.g. `null`, `yes`,
+// `~` or `8080`, and arrays and maps are inserted as flow style JSON.
+func escapeSlotValue(slot StrSlot, ctx slotContext, value string, typed interface{}, prev byte, next byte) (string, error) {
+	unsafe := func(reason string) error {
+		return &UnsafeStrSlotError{Slot: slot, Context: ctx.kind, Reason: reason}
+	}
+
+	switch ctx.kind {
+	case slotContextDoubleQuoted:
+		return quoteDoubleContent(value), nil
+	case slotContextSingleQuoted:
+		if containsControl(value, false) {
+			return "", unsafe("line breaks and control characters are not allowed")
+		}
+		return strings.ReplaceAll(value, "'", "''"), nil
+	case slotContextBlock:
+		if containsControl(value, true) {
+			return "", unsafe("control characters are not allowed")
+		}
+		// keep the continuation lines inside the block
+		return strings.ReplaceAll(value, "\n", "\n"+ctx.indent), nil
+	case slotContextComment:
+		if containsControl(value, false) {
+			return "", unsafe("line breaks and control characters are not allowed")
+		}
+		return value, nil
+	}
+
+	wholeScalar := ctx.atStart && ctx.atEnd
+	if wholeScalar && !ctx.key && isJSONCollection(typed) {
+		// value is the json text of the array or map, a valid yaml flow collection
+		return value, nil
+	}
+	if reason := checkPlainValue(value, ctx, prev, next); reason != "" {
+		if wholeScalar {
+			// the placeholder is the whole scalar, quote it
+			return `"` + quoteDoubleContent(value) + `"`, nil
+		}
+		return "", unsafe(reason)
+	}
+	if _, isString := typed.(string); isString && wholeScalar && !loadsAsString(value) {
+		return `"` + quoteDoubleContent(value) + `"`, nil
+	}
+	return value, nil
+}
+
+// isJSONCollection reports whether value is encoded as a json array or object.
+func isJSONCollection(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	converted, err := ToJSONValue(value)
+	if err != nil {
+		return false
+	}
+	switch converted.(type) {
+	case []interface{}, map[string]interface{}:
+		return true
+	}
+	return false
+}
+
+// loadsAsString reports whether a plain scalar is loaded as the same string by the manifest loader (yaml 1.1).
+func loadsAsString(value string) bool {
+	var loaded interface{}
+	if err := sigsyaml.Unmarshal([]byte(value), &loaded); err != nil {
+		return false
+	}
+	s, ok := loaded.(string)
+	return ok && s == value
+}
+
+// checkPlainValue returns the reason why value cannot be a part of a plain scalar, or "" if it is safe.
+func checkPlainValue(value string, ctx slotContext, prev byte, next byte) string {
+	if value == "" {
+		return ""
+	}
+	if containsControl(value, false) {
+		return "line breaks and control characters are not allowed"
+	}
+	surrounded := string(prev) + value + string(next)
+	if strings.Contains(surrounded, ": ") || strings.Contains(surrounded, ":\t") {
+		return "': ' would start a mapping value"
+	}
+	if strings.Contains(surrounded, " #") || strings.Contains(surrounded, "\t#") {
+		return "' #' would start a comment"
+	}
+	if ctx.flow && strings.ContainsAny(value, ",[]{}") {
+		return "flow indicators are not allowed in a flow collection"
+	}
+	if ctx.atStart {
+		first := value[0]
+		if strings.IndexByte(",[]{}#&*!|>'\"%@`", first) >= 0 || unicode.IsSpace(rune(first)) {
+			return fmt.Sprintf("a plain scalar cannot start with %q", first)
+		}
+		if strings.IndexByte("-?:", first) >= 0 && (len(value) == 1 && next == ' ' || len(value) > 1 && value[1] == ' ') {
+			return fmt.Sprintf("a plain scalar cannot start with %q followed by a space", first)
+		}
+		if ctx.column == 0 && (strings.HasPrefix(value, "---") || strings.HasPrefix(value, "...")) {
+			return "a document marker is not allowed"
+		}
+	}
+	if ctx.atEnd && unicode.IsSpace(rune(value[len(value)-1])) {
+		return "trailing spaces would be trimmed"
+	}
+	return ""
+}
+
+// quoteDoubleContent escapes value as the content of a double-quoted yaml scalar or a JSON string.
+func quoteDoubleContent(value string) string {
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	// encoding a string never fails
+	_ = encoder.Encode(value)
+	quoted := strings.TrimSuffix(buf.String(), "\n")
+	return quoted[1 : len(quoted)-1]
+}
+
+func containsControl(value string, allowLineBreak bool) bool {
+	for _, r := range value {
+		if r == '\t' || allowLineBreak && r == '\n' {
+			continue
+		}
+		if unicode.IsControl(r) || r == '\uFEFF' {
+			return true
+		}
+	}
+	return false
+}
+
+// detectSlotContexts finds the yaml context of every top-level placeholder of the template.
+// The detection is lexical: it follows quotes, comments, flow collections and block scalars
+// but does not validate the document.
+func detectSlotContexts(tmpl string, slots []StrSlot) []slotContext {
+	contexts := make([]slotContext, len(slots))
+	k := 0
+
+	var (
+		inDouble, inSingle, inComment bool
+		flowDepth                     int
+		plainStarted                  bool
+		blockParent                   = -1 // indentation of the node owning the current block scalar
+		pendingBlockParent            = -1
+		lineStart, lineIndent         int
+		lineKeyCol                    = -1
+		inBlockLine                   bool
+	)
+
+	startLine := func(i int) {
+		lineStart = i
+		lineKeyCol = -1
+		lineIndent = 0
+		for i+lineIndent < len(tmpl) && tmpl[i+lineIndent] == ' ' {
+			lineIndent++
+		}
+		if pendingBlockParent >= 0 {
+			blockParent = pendingBlockParent
+			pendingBlockParent = -1
+		}
+		inBlockLine = false
+		if blockParent >= 0 {
+			rest := tmpl[i+lineIndent:]
+			blank := len(rest) == 0 || rest[0] == '\n' || rest[0] == '\r'
+			if blank || lineIndent > blockParent {
+				inBlockLine = true
+			} else {
+				blockParent = -1
+			}
+		}
+		inComment = false
+		if !inDouble && !inSingle {
+			plainStarted = false
+		}
+	}
+
+	startLine(0)
+	for i := 0; i < len(tmpl); {
+		if k < len(slots) && i == slots[k].Offset {
+			ctx := slotContext{
+				column: i - lineStart,
+				indent: tmpl[lineStart : lineStart+lineIndent],
+				flow:   flowDepth > 0,
+			}
+			end := i + len(slots[k].Raw)
+			switch {
+			case inBlockLine:
+				ctx.kind = slotContextBlock
+			case inComment:
+				ctx.kind = slotContextComment
+			case inDouble:
+				ctx.kind = slotContextDoubleQuoted
+			case inSingle:
+				ctx.kind = slotContextSingleQuoted
+			default:
+				ctx.kind = slotContextPlain
+				ctx.atStart = !plainStarted
+				ctx.atEnd = isPlainScalarEnd(tmpl, end, ctx.flow)
+				ctx.key = isMappingKeyEnd(tmpl, end)
+				plainStarted = true
+				if lineKeyCol < 0 {
+					lineKeyCol = i - lineStart
+				}
+			}
+			contexts[k] = ctx
+			k++
+			i = end
+			continue
+		}
+
+		c := tmpl[i]
+		if c == '\n' {
+			startLine(i + 1)
+			i++
+			continue
+		}
+		if inBlockLine || inComment {
+			i++
+			continue
+		}
+		if inDouble {
+			if c == '\\' && i+1 < len(tmpl) && tmpl[i+1] != '$' && tmpl[i+1] != '\n' {
+				i += 2
+				continue
+			}
+			if c == '"' {
+				inDouble = false
+			}
+			i++
+			continue
+		}
+		if inSingle {
+			if c == '\'' {
+				if i+1 < len(tmpl) && tmpl[i+1] == '\'' {
+					i += 2
+					continue
+				}
+				inSingle = false
+			}
+			i++
+			continue
+		}
+
+		nextIsSpace := i+1 >= len(tmpl) || strings.IndexByte(" \t\r\n", tmpl[i+1]) >= 0
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+		case c == '#' && (i == lineStart || tmpl[i-1] == ' ' || tmpl[i-1] == '\t'):
+			inComment = true
+		case (c == '"' || c == '\'') && !plainStarted:
+			inDouble = c == '"'
+			inSingle = c == '\''
+			plainStarted = true
+			if lineKeyCol < 0 {
+				lineKeyCol = i - lineStart
+			}
+		case c == ':' && (nextIsSpace || flowDepth > 0 && strings.IndexByte(",]}", tmpl[i+1]) >= 0):
+			plainStarted = false
+		case (c == '-' || c == '?') && !plainStarted && nextIsSpace:
+		case (c == '[' || c == '{') && (!plainStarted || flowDepth > 0):
+			flowDepth++
+			plainStarted = false
+		case (c == ']' || c == '}') && flowDepth > 0:
+			flowDepth--
+			plainStarted = true
+		case c == ',' && flowDepth > 0:
+			plainStarted = false
+		case (c == '|' || c == '>') && !plainStarted && flowDepth == 0 && isBlockScalarHeader(tmpl[i:]):
+			if lineKeyCol >= 0 {
+				pendingBlockParent = lineKeyCol
+			} else {
+				pendingBlockParent = lineIndent
+			}
+			inComment = true // skip the rest of the header
+		case (c == '&' || c == '!') && !plainStarted:
+			// anchor or tag, the node follows
+			for i+1 < len(tmpl) && strings.IndexByte(" \t\r\n", tmpl[i+1]) < 0 {
+				i++
+			}
+		default:
+			plainStarted = true
+			if lineKeyCol < 0 {
+				lineKeyCol = i - lineStart
+			}
+		}
+		i++
+	}
+	return contexts
+}
+
+// isPlainScalarEnd reports whether a plain scalar ends at position i of the template.
+func isPlainScalarEnd(tmpl string, i int, flow bool) bool {
+	j := i
+	for j < len(tmpl) && (tmpl[j] == ' ' || tmpl[j] == '\t') {
+		j++
+	}
+	if j >= len(tmpl) || tmpl[j] == '\n' || tmpl[j] == '\r' {
+		return true
+	}
+	switch {
+	case tmpl[j] == '#' && j > i:
+		return true
+	case tmpl[j] == ':' && (j+1 >= len(tmpl) || strings.IndexByte(" \t\r\n", tmpl[j+1]) >= 0):
+		return true
+	case flow && strings.IndexByte(",]}", tmpl[j]) >= 0:
+		return true
+	}
+	return false
+}
+
+// isMappingKeyEnd reports whether a mapping key ends at position i of the template.
+func isMappingKeyEnd(tmpl string, i int) bool {
+	for i < len(tmpl) && (tmpl[i] == ' ' || tmpl[i] == '\t') {
+		i++
+	}
+	return i < len(tmpl) && tmpl[i] == ':' && (i+1 >= len(tmpl) || strings.IndexByte(" \t\r\n,]}", tmpl[i+1]) >= 0)
+}
+
+// isBlockScalarHeader reports whether s starts with a block scalar header like `|`, `>-` or `|2+ # comment`.
+func isBlockScalarHeader(s string) bool {
+	i := 1
+	for i < len(s) && strings.IndexByte("0123456789+-", s[i]) >= 0 {
+		i++
+	}
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i >= len(s) || s[i] == '\n' || s[i] == '\r' || s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t')
+}
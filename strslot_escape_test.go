@@ -0,0 +1,184 @@
+package structemplate
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var escapeTemplate string = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ${NAME}
+  annotations:
+    note: "${NOTE}"
+    single: '${SINGLE}'
+    # owner: ${OWNER}
+data:
+  plain: ${PLAIN}
+  image: ${REGISTRY}/${IMAGE}
+  script: |
+    echo start
+    ${SCRIPT}
+  flow: [${FLOW}, b]
+`
+
+func TestRenderStrSlotTemplate_EscapeYAML(t *testing.T) {
+	values := map[string]interface{}{
+		"NAME":     "demo",
+		"NOTE":     "say \"hi\"\nnext: line",
+		"SINGLE":   "it's",
+		"OWNER":    "me",
+		"PLAIN":    "a: b #c\ninjected: true",
+		"REGISTRY": "docker.io",
+		"IMAGE":    "app:1.0",
+		"SCRIPT":   "line1\nline2: x",
+		"FLOW":     "x, y]",
+	}
+	result, _, err := RenderStrSlotTemplateWithOptions(escapeTemplate, values, nil, StrSlotRenderOptions{EscapeMode: StrSlotEscapeYAML})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	objs, err := LoadManifestsFromString(result)
+	if err != nil {
+		t.Logf("Rendered template is invalid: %+v\n%s", err, result)
+		t.FailNow()
+		return
+	}
+	obj := objs.Objects[0]
+	annotations := obj.GetAnnotations()
+	data, _, _ := unstructured.NestedMap(obj.Object, "data")
+	flow, _, _ := unstructured.NestedSlice(obj.Object, "data", "flow")
+	if obj.GetName() != "demo" || annotations["note"] != values["NOTE"] || annotations["single"] != "it's" ||
+		data["plain"] != values["PLAIN"] || data["image"] != "docker.io/app:1.0" ||
+		data["script"] != "echo start\nline1\nline2: x\n" || len(data) != 4 || len(flow) != 2 || flow[0] != values["FLOW"] {
+		t.Logf("Unexpected result:\n%s", result)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderStrSlotTemplate_EscapeJSON(t *testing.T) {
+	tmpl := `{"kind": "ConfigMap", "apiVersion": "v1", "metadata": {"name": "${NAME}"}, "data": {"count": "${COUNT}", "key": ${VALUE}}}`
+	result, _, err := RenderStrSlotTemplateWithOptions(tmpl, map[string]interface{}{"NAME": `a"b\c`, "COUNT": 3, "VALUE": "x, y"}, nil,
+		StrSlotRenderOptions{EscapeMode: StrSlotEscapeYAML})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	objs, err := LoadManifestsFromString(result)
+	if err != nil {
+		t.Logf("Rendered template is invalid: %+v\n%s", err, result)
+		t.FailNow()
+		return
+	}
+	data, _, _ := unstructured.NestedStringMap(objs.Objects[0].Object, "data")
+	if objs.Objects[0].GetName() != `a"b\c` || data["count"] != "3" || data["key"] != "x, y" {
+		t.Logf("Unexpected result: %s", result)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderStrSlotTemplate_EscapeUnsafe(t *testing.T) {
+	cases := []struct {
+		tmpl   string
+		values map[string]interface{}
+	}{
+		{tmpl: "image: ${REGISTRY}/${IMAGE}\n", values: map[string]interface{}{"REGISTRY": "docker.io", "IMAGE": "app: x"}},
+		{tmpl: "image: ${REGISTRY}/${IMAGE}\n", values: map[string]interface{}{"REGISTRY": "docker.io", "IMAGE": "app\nkey: x"}},
+		{tmpl: "key: value # ${COMMENT}\n", values: map[string]interface{}{"COMMENT": "a\nkey: x"}},
+		{tmpl: "key: 'prefix ${VALUE}'\n", values: map[string]interface{}{"VALUE": "a\nb"}},
+		{tmpl: "key: ${VALUE} suffix\n", values: map[string]interface{}{"VALUE": "#a"}},
+	}
+	for i, c := range cases {
+		_, _, err := RenderStrSlotTemplateWithOptions(c.tmpl, c.values, nil, StrSlotRenderOptions{EscapeMode: StrSlotEscapeYAML})
+		if _, ok := err.(*UnsafeStrSlotError); !ok {
+			t.Logf("Case %d: expected UnsafeStrSlotError, got %v", i, err)
+			t.FailNow()
+			return
+		}
+	}
+
+	// values safe in a plain scalar are kept as they are
+	result, _, err := RenderStrSlotTemplateWithOptions("replicas: ${REPLICAS}\nimage: ${REGISTRY}/${IMAGE}:${TAG}\n",
+		map[string]interface{}{"REPLICAS": 3, "REGISTRY": "docker.io", "IMAGE": "app", "TAG": "v1"}, nil,
+		StrSlotRenderOptions{EscapeMode: StrSlotEscapeYAML})
+	if err != nil || result != "replicas: 3\nimage: docker.io/app:v1\n" {
+		t.Logf("Unexpected result: %s, %v", result, err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderStrSlotTemplate_EscapeYAMLScalars(t *testing.T) {
+	tmpl := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scalars
+data:
+  nullText: ${NULL}
+  boolText: ${BOOL}
+  tilde: ${TILDE}
+  marker: ${MARKER}
+  number: ${NUMBER}
+  empty: ${EMPTY}
+  text: ${TEXT}
+  lowered: ${LOWERED | lower}
+spec:
+  replicas: ${REPLICAS}
+  port: ${PORT}
+  args: ${ARGS}
+  labels: ${LABELS}
+  flow: [${ARGS}, x]
+`
+	values := map[string]interface{}{"NULL": "null", "BOOL": "yes", "TILDE": "~", "MARKER": "---", "NUMBER": "8080",
+		"EMPTY": "", "TEXT": "plain text", "LOWERED": "NO", "REPLICAS": 3,
+		"ARGS": []string{"--v", "a: b"}, "LABELS": map[string]int{"tier": 1}}
+	result, _, err := RenderStrSlotTemplateWithOptions(tmpl, values, map[string]string{"PORT": "80"}, StrSlotRenderOptions{EscapeMode: StrSlotEscapeYAML})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	objs, err := LoadManifestsFromString(result)
+	if err != nil {
+		t.Logf("Rendered template is invalid: %+v\n%s", err, result)
+		t.FailNow()
+		return
+	}
+	obj := objs.Objects[0].Object
+	data, _, _ := unstructured.NestedMap(obj, "data")
+	for key, code := range map[string]string{"nullText": "NULL", "boolText": "BOOL", "tilde": "TILDE", "marker": "MARKER", "number": "NUMBER", "empty": "EMPTY", "text": "TEXT"} {
+		if data[key] != values[code] {
+			t.Logf("String value of %s is not kept: %#v\n%s", code, data[key], result)
+			t.FailNow()
+			return
+		}
+	}
+	// the type of filtered values is checked after the filters
+	if data["lowered"] != "no" {
+		t.Logf("Filtered string value is not kept: %#v\n%s", data["lowered"], result)
+		t.FailNow()
+		return
+	}
+	// values which are not strings keep their types
+	spec := obj["spec"].(map[string]interface{})
+	if spec["replicas"] != int64(3) || spec["port"] != int64(80) {
+		t.Logf("Unexpected spec: %v", spec)
+		t.FailNow()
+		return
+	}
+	// arrays and maps are inserted as flow collections
+	args, _, _ := unstructured.NestedStringSlice(obj, "spec", "args")
+	labels, _, _ := unstructured.NestedMap(obj, "spec", "labels")
+	flow, _, _ := unstructured.NestedSlice(obj, "spec", "flow")
+	if len(args) != 2 || args[1] != "a: b" || labels["tier"] != int64(1) || len(flow) != 2 {
+		t.Logf("Unexpected collections: %v\n%s", spec, result)
+		t.FailNow()
+		return
+	}
+}
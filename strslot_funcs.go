@@ -0,0 +1,264 @@
+package structemplate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// StrSlotFunc transforms the value of a StrSlot variable in a filter pipeline like `${NAME | trunc 63 | lower}`.
+// value is the typed param value for the first filter and the result of the previous filter for the others,
+// args are the literal arguments written after the filter name.
+type StrSlotFunc func(value interface{}, args ...string) (interface{}, error)
+
+// StrSlotFilter is a filter applied to the value of a StrSlot placeholder.
+type StrSlotFilter struct {
+	Name string   `json:"name"`           // 过滤器名称
+	Args []string `json:"args,omitempty"` // 过滤器参数
+}
+
+func (f StrSlotFilter) String() string {
+	if len(f.Args) < 1 {
+		return f.Name
+	}
+	return f.Name + " " + strings.Join(f.Args, " ")
+}
+
+var strSlotFuncs = map[string]StrSlotFunc{
+	"lower":    stringFunc(strings.ToLower),
+	"upper":    stringFunc(strings.ToUpper),
+	"trim":     stringFunc(strings.TrimSpace),
+	"b64enc":   stringFunc(func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }),
+	"b64dec":   b64decFunc,
+	"trunc":    truncFunc,
+	"dnsLabel": dnsLabelFunc,
+	"toJson":   toJsonFunc,
+	"toYaml":   toYamlFunc,
+	"join":     joinFunc,
+	"quote":    stringFunc(strconv.Quote),
+	"replace":  replaceFunc,
+}
+
+var strSlotFuncsLock sync.RWMutex
+
+// RegisterStrSlotFunc registers a filter function usable in all StrSlot templates, an existing function with the
+// same name (including the built-in ones) is replaced. Use StrSlotRenderOptions.Funcs for functions of a single rendering.
+func RegisterStrSlotFunc(name string, fn StrSlotFunc) {
+	strSlotFuncsLock.Lock()
+	defer strSlotFuncsLock.Unlock()
+	strSlotFuncs[name] = fn
+}
+
+func lookupStrSlotFunc(name string, funcs map[string]StrSlotFunc) (StrSlotFunc, bool) {
+	if fn, ok := funcs[name]; ok {
+		return fn, true
+	}
+	strSlotFuncsLock.RLock()
+	defer strSlotFuncsLock.RUnlock()
+	fn, ok := strSlotFuncs[name]
+	return fn, ok
+}
+
+// applyStrSlotFilters runs value through the filters of a placeholder in order.
+func applyStrSlotFilters(value interface{}, filters []StrSlotFilter, funcs map[string]StrSlotFunc) (interface{}, error) {
+	for _, filter := range filters {
+		fn, ok := lookupStrSlotFunc(filter.Name, funcs)
+		if !ok {
+			return nil, fmt.Errorf("unknown filter: %s", filter.Name)
+		}
+		result, err := fn(value, filter.Args...)
+		if err != nil {
+			return nil, errors.Wrap(err, "filter "+filter.String()+" failed")
+		}
+		value = result
+	}
+	return value, nil
+}
+
+// parseStrSlotFilters parses the text after the first ` |` of a placeholder, e.g. `trunc 63 | join ", "`.
+// Arguments are separated by spaces and may be quoted with `"` or `'`.
+func parseStrSlotFilters(text string) ([]StrSlotFilter, error) {
+	filters := make([]StrSlotFilter, 0)
+	tokens := make([]string, 0)
+	flush := func() error {
+		if len(tokens) < 1 {
+			return errors.New("empty filter")
+		}
+		filters = append(filters, StrSlotFilter{Name: tokens[0], Args: tokens[1:]})
+		tokens = make([]string, 0)
+		return nil
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '|':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(text) && text[end] != c {
+				if c == '"' && text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("unterminated quoted argument: %s", text[i:])
+			}
+			token, err := unquoteFilterArg(text[i : end+1])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			i = end + 1
+		default:
+			end := i
+			for end < len(text) && strings.IndexByte(" \t|", text[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, text[i:end])
+			i = end
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+func unquoteFilterArg(quoted string) (string, error) {
+	if quoted[0] == '\'' {
+		return quoted[1 : len(quoted)-1], nil
+	}
+	s, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid quoted argument "+quoted)
+	}
+	return s, nil
+}
+
+// stringFunc adapts a string transformation to a StrSlotFunc, non-string values are converted to strings first.
+func stringFunc(fn func(string) string) StrSlotFunc {
+	return func(value interface{}, args ...string) (interface{}, error) {
+		s, err := stringifyValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return fn(s), nil
+	}
+}
+
+func b64decFunc(value interface{}, args ...string) (interface{}, error) {
+	s, err := stringifyValue(value)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(decoded), nil
+}
+
+// truncFunc keeps the first N characters, or the last -N characters when N is negative.
+func truncFunc(value interface{}, args ...string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, errors.New("usage: trunc LENGTH")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid length")
+	}
+	s, err := stringifyValue(value)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	switch {
+	case n >= 0 && n < len(runes):
+		return string(runes[:n]), nil
+	case n < 0 && -n < len(runes):
+		return string(runes[len(runes)+n:]), nil
+	}
+	return s, nil
+}
+
+var dnsLabelInvalidChars = regexp.MustCompile("[^a-z0-9-]+")
+
+// dnsLabelFunc converts the value to a RFC 1123 label: lower case alphanumeric characters or '-',
+// starting and ending with an alphanumeric character and at most 63 characters.
+func dnsLabelFunc(value interface{}, args ...string) (interface{}, error) {
+	s, err := stringifyValue(value)
+	if err != nil {
+		return nil, err
+	}
+	label := dnsLabelInvalidChars.ReplaceAllString(strings.ToLower(s), "-")
+	label = strings.Trim(label, "-")
+	if len(label) > 63 {
+		label = strings.TrimRight(label[:63], "-")
+	}
+	if label == "" {
+		return nil, fmt.Errorf("%q cannot be converted to a DNS label", s)
+	}
+	return label, nil
+}
+
+func toJsonFunc(value interface{}, args ...string) (interface{}, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func toYamlFunc(value interface{}, args ...string) (interface{}, error) {
+	b, err := sigsyaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// joinFunc joins the elements of an array with the separator, "," by default.
+func joinFunc(value interface{}, args ...string) (interface{}, error) {
+	sep := ","
+	if len(args) > 0 {
+		sep = args[0]
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	elems := toInterfaceSlice(value)
+	strs := make([]string, 0, len(elems))
+	for _, e := range elems {
+		s, err := stringifyValue(e)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strings.Join(strs, sep), nil
+}
+
+func replaceFunc(value interface{}, args ...string) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, errors.New("usage: replace OLD NEW")
+	}
+	s, err := stringifyValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(s, args[0], args[1]), nil
+}
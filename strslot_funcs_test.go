@@ -0,0 +1,96 @@
+package structemplate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderStrSlotTemplate_Filters(t *testing.T) {
+	tmpl := `name: ${NAME | trunc 20 | dnsLabel}
+password: ${PASSWORD | b64enc}
+config: '${CFG | toJson}'
+tags: ${TAGS | join ", "}
+owner: ${OWNER | lower | replace " " "-"}
+message: ${MSG:-a | b}
+`
+	values := map[string]interface{}{
+		"NAME":     "My_Application.Service-Name",
+		"PASSWORD": "s3cret",
+		"CFG":      map[string]interface{}{"debug": true},
+		"TAGS":     []interface{}{"a", "b", 3},
+		"OWNER":    "Platform Team",
+	}
+	result, _, err := RenderStrSlotTemplate(tmpl, values, nil)
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	expected := `name: my-application-servi
+password: czNjcmV0
+config: '{"debug":true}'
+tags: a, b, 3
+owner: platform-team
+message: a | b
+`
+	if result != expected {
+		t.Logf("Unexpected result: %s", result)
+		t.FailNow()
+		return
+	}
+
+	slots, _ := FindStrSlots(tmpl)
+	if slots[0].Name != "NAME" || len(slots[0].Filters) != 2 || slots[0].Filters[0].Args[0] != "20" ||
+		slots[4].Filters[1].Args[0] != " " || slots[5].Args != "a | b" || len(slots[5].Filters) != 0 {
+		t.Logf("Unexpected slots: %+v", slots)
+		t.FailNow()
+		return
+	}
+
+	// braces inside quoted arguments do not close the placeholder
+	result, _, err = RenderStrSlotTemplate(`a: ${N | replace "}" "]"} ${N | replace '{' '['}`, map[string]interface{}{"N": "{x}"}, nil)
+	if err != nil || result != "a: {x] [x}" {
+		t.Logf("Unexpected result with quoted braces: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+
+	// a space is needed before the '|'
+	_, _, err = RenderStrSlotTemplate("a: ${N|lower}", map[string]interface{}{"N": "X"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "a space is needed before the '|'") || !strings.Contains(err.Error(), "${N | lower}") {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderStrSlotTemplate_CustomFilters(t *testing.T) {
+	RegisterStrSlotFunc("reverse", func(value interface{}, args ...string) (interface{}, error) {
+		runes := []rune(value.(string))
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	})
+	opts := StrSlotRenderOptions{
+		EscapeMode: StrSlotEscapeYAML,
+		Funcs: map[string]StrSlotFunc{
+			"suffix": func(value interface{}, args ...string) (interface{}, error) {
+				return value.(string) + args[0], nil
+			},
+		},
+	}
+	result, _, err := RenderStrSlotTemplateWithOptions("name: ${NAME | reverse | suffix ': x'}\n", map[string]interface{}{"NAME": "abc"}, nil, opts)
+	if err != nil || result != "name: \"cba: x\"\n" {
+		t.Logf("Unexpected result: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+
+	_, _, err = RenderStrSlotTemplate("name: ${NAME | unknown}\n", map[string]interface{}{"NAME": "abc"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown filter") {
+		t.Logf("Unknown filter should fail: %v", err)
+		t.FailNow()
+		return
+	}
+}
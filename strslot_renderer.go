@@ -2,11 +2,51 @@ package structemplate
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/drone/envsubst/v2"
 	"github.com/pkg/errors"
 )
 
+// Handling of StrSlot variables without value
+const (
+	// StrSlotMissingKeyEmpty renders missing variables as empty strings, the default behaviour.
+	StrSlotMissingKeyEmpty = ""
+	// StrSlotMissingKeyError fails the rendering with a *MissingStrSlotError listing every missing variable.
+	StrSlotMissingKeyError = "Error"
+	// StrSlotMissingKeyKeep leaves the placeholders of missing variables intact for a later rendering pass.
+	StrSlotMissingKeyKeep = "Keep"
+)
+
+// StrSlotRenderOptions controls the rendering of StrSlot templates.
+type StrSlotRenderOptions struct {
+	MissingKeyMode string `json:"missingKeyMode,omitempty"` // 缺失变量的处理方式: 空(渲染为空字符串), Error, Keep
+	EscapeMode     string `json:"escapeMode,omitempty"`     // 变量值的转义方式: 空(不转义), YAML(根据占位符在yaml/json中的位置转义)
+
+	// 自定义占位符分隔符, 如 `[[`和`]]`, `{{`和`}}`, `@@`和`@@`. 为空时使用`${`和`}`, 此时模板中原有的`${...}`不会被替换
+	LeftDelim     string `json:"leftDelim,omitempty"`
+	RightDelim    string `json:"rightDelim,omitempty"`
+	LiteralEscape string `json:"literalEscape,omitempty"` // 使用自定义分隔符时, 放在左分隔符前表示原样输出的转义序列, 默认为`\`
+
+	TypedScalars bool `json:"typedScalars,omitempty"` // Template.Render中, 整个标量为占位符时解码后按参数值的类型替换, 见RenderStrSlotTypedTemplate
+
+	Funcs map[string]StrSlotFunc `json:"-"` // 仅用于本次渲染的过滤器函数, 优先于RegisterStrSlotFunc注册的函数
+}
+
+// MissingStrSlotError is returned in StrSlotMissingKeyError mode when variables without default value have no value.
+type MissingStrSlotError struct {
+	Slots []StrSlot // placeholders of the missing variables
+}
+
+func (e *MissingStrSlotError) Error() string {
+	missing := make([]string, 0, len(e.Slots))
+	for _, slot := range e.Slots {
+		missing = append(missing, fmt.Sprintf("%s (line %d, column %d)", slot.Name, slot.Line, slot.Column))
+	}
+	return "missing values of StrSlot variables: " + strings.Join(missing, ", ")
+}
+
 // RenderStrSlotTemplate Rendering a string template containing StrSlot params with the values map.
 // @Param tmpl The template string
 // @Param valuesMapOfInterface (optional) values of parameters
@@ -15,7 +55,30 @@ import (
 // @Return missingKeys missing keys that defined in the template without default value and no value is provided
 // @Return err Other errors
 func RenderStrSlotTemplate(tmpl string, valuesMapOfInterface map[string]interface{}, valuesMapOfString map[string]string) (result string, missingKeys []string, err error) {
-	envTmpl, err := envsubst.Parse(tmpl)
+	return RenderStrSlotTemplateWithOptions(tmpl, valuesMapOfInterface, valuesMapOfString, StrSlotRenderOptions{})
+}
+
+// RenderStrSlotTemplateWithOptions Rendering a string template containing StrSlot params like RenderStrSlotTemplate
+// with the options controlling how missing variables are handled.
+// In StrSlotMissingKeyError mode the returned error is a *MissingStrSlotError when any variable is missing.
+// In StrSlotEscapeYAML mode the returned error is an *UnsafeStrSlotError when a value cannot be safely escaped.
+func RenderStrSlotTemplateWithOptions(tmpl string, valuesMapOfInterface map[string]interface{}, valuesMapOfString map[string]string, opts StrSlotRenderOptions) (result string, missingKeys []string, err error) {
+	return renderStrSlotTemplate(tmpl, valuesMapOfInterface, valuesMapOfString, opts, nil)
+}
+
+// renderStrSlotTemplate implements RenderStrSlotTemplateWithOptions. With typedValues not nil, whole plain scalar
+// placeholders having a value are rendered as unique markers, and typedValues maps the markers to the typed values.
+func renderStrSlotTemplate(tmpl string, valuesMapOfInterface map[string]interface{}, valuesMapOfString map[string]string, opts StrSlotRenderOptions, typedValues map[string]interface{}) (result string, missingKeys []string, err error) {
+	syntax, err := newStrSlotSyntax(opts)
+	if err != nil {
+		return "", nil, err
+	}
+	if syntax != nil {
+		if tmpl, err = syntax.convert(tmpl); err != nil {
+			return "", nil, errors.Wrap(err, "cannot parse the template")
+		}
+	}
+	slots, err := scanStrSlots(tmpl)
 	if err != nil {
 		return "", nil, errors.Wrap(err, "cannot parse the template")
 	}
@@ -25,14 +88,41 @@ func RenderStrSlotTemplate(tmpl string, valuesMapOfInterface map[string]interfac
 	if valuesMapOfString == nil {
 		valuesMapOfString = make(map[string]string, 0)
 	}
+	lookupValue := func(key string) (interface{}, bool) {
+		if vs, ok := valuesMapOfString[key]; ok {
+			return vs, true
+		}
+		v, ok := valuesMapOfInterface[key]
+		return v, ok
+	}
+
+	// find the missing variables and the placeholders that cannot be resolved
+	missingSlots := make([]StrSlot, 0)
+	unresolved := make([]StrSlot, 0)
+	for _, slot := range slots {
+		slotMissing := findMissingSlots(slot, lookupValue)
+		if len(slotMissing) > 0 {
+			unresolved = append(unresolved, slot)
+			missingSlots = append(missingSlots, slotMissing...)
+		}
+	}
+	missingKeys = uniqueSlotNames(missingSlots)
+	var keep []StrSlot
+
+	switch opts.MissingKeyMode {
+	case StrSlotMissingKeyError:
+		if len(missingSlots) > 0 {
+			return "", missingKeys, &MissingStrSlotError{Slots: syntax.originalSlots(missingSlots)}
+		}
+	case StrSlotMissingKeyKeep:
+		keep = unresolved
+	}
 
-	var missingParams []string
 	execFunc := func(key string) string {
 		v, iok := valuesMapOfInterface[key]
 		vs, sok := valuesMapOfString[key]
 		if !sok && !iok {
 			// missing param
-			missingParams = append(missingParams, key)
 			return ""
 		}
 
@@ -46,7 +136,8 @@ func RenderStrSlotTemplate(tmpl string, valuesMapOfInterface map[string]interfac
 			default:
 				valueB, err := json.Marshal(v)
 				if err != nil {
-					missingParams = append(missingParams, key)
+					// value cannot be rendered, take it as missing
+					missingKeys = appendUnique(missingKeys, key)
 					return ""
 				}
 				valueStr = string(valueB)
@@ -55,9 +146,268 @@ func RenderStrSlotTemplate(tmpl string, valuesMapOfInterface map[string]interfac
 		return valueStr
 	}
 
+	// renderSlot returns the text of a placeholder and the typed value it renders,
+	// which is nil when the text is not a param value, e.g. a default value or a value of valuesMapOfString
+	renderSlot := func(slot StrSlot) (string, interface{}, error) {
+		if len(slot.Filters) < 1 {
+			text, err := envsubst.Eval(slot.Raw, execFunc)
+			if err != nil {
+				return "", nil, err
+			}
+			if _, ok := valuesMapOfString[slot.Name]; ok {
+				return text, nil, nil
+			}
+			if value, ok := valuesMapOfInterface[slot.Name]; ok && slotUsesValue(slot, value) {
+				return text, value, nil
+			}
+			return text, nil, nil
+		}
+		var value interface{}
+		if slot.Operator == "" {
+			// the filters receive the typed value
+			var ok bool
+			if value, ok = lookupValue(slot.Name); !ok {
+				value = ""
+			}
+		} else {
+			if value, err = envsubst.Eval(slot.expression(), execFunc); err != nil {
+				return "", nil, err
+			}
+		}
+		if value, err = applyStrSlotFilters(value, slot.Filters, opts.Funcs); err != nil {
+			return "", nil, err
+		}
+		text, err := stringifyValue(value)
+		return text, value, err
+	}
+
+	replacements := make(map[int]string, len(slots))
+	for _, slot := range keep {
+		replacements[slot.Offset] = syntax.original(slot).Raw
+	}
+	var contexts []slotContext
+	if typedValues != nil || opts.EscapeMode == StrSlotEscapeYAML {
+		contexts = detectSlotContexts(tmpl, slots)
+	}
+	if typedValues != nil {
+		markerPrefix := typedSlotMarkerPrefix(tmpl)
+		for i, slot := range slots {
+			ctx := contexts[i]
+			if _, ok := replacements[slot.Offset]; ok || ctx.kind != slotContextPlain || !ctx.atStart || !ctx.atEnd || ctx.key {
+				continue
+			}
+			value, ok := lookupValue(slot.Name)
+			if !ok || !slotUsesValue(slot, value) {
+				// rendered as text, e.g. with the default value
+				continue
+			}
+			if len(slot.Filters) > 0 {
+				if value, err = applyStrSlotFilters(value, slot.Filters, opts.Funcs); err != nil {
+					return "", missingKeys, renderSlotError(err, syntax.original(slot))
+				}
+			}
+			marker := fmt.Sprintf("%s%d", markerPrefix, len(typedValues))
+			typedValues[marker] = value
+			replacements[slot.Offset] = marker
+		}
+	}
+
+	switch opts.EscapeMode {
+	case StrSlotEscapeNone:
+		for _, slot := range slots {
+			if _, ok := replacements[slot.Offset]; ok || len(slot.Filters) < 1 {
+				continue
+			}
+			value, _, err := renderSlot(slot)
+			if err != nil {
+				return "", missingKeys, renderSlotError(err, syntax.original(slot))
+			}
+			replacements[slot.Offset] = value
+		}
+	case StrSlotEscapeYAML:
+		if err := escapeStrSlotValues(tmpl, slots, contexts, replacements, renderSlot, syntax); err != nil {
+			return "", missingKeys, err
+		}
+	default:
+		return "", nil, fmt.Errorf("unknown escape mode: %s", opts.EscapeMode)
+	}
+	// in StrSlotMissingKeyKeep mode the escaped `$$` stay escaped for a later rendering pass,
+	// with custom delimiters a '$' is not special and the escaped delimiters are kept by syntax.convert
+	tmpl = replaceStrSlots(tmpl, slots, replacements, opts.MissingKeyMode == StrSlotMissingKeyKeep && syntax == nil)
+
+	envTmpl, err := envsubst.Parse(tmpl)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "cannot parse the template")
+	}
+
 	result, err = envTmpl.Execute(execFunc)
 	if err != nil {
 		return "", nil, errors.Wrap(err, "cannot render the template")
 	}
 	return result, missingKeys, nil
 }
+
+// findMissingSlots returns the placeholders within slot whose variable is missing and not covered by a default value.
+// The nested placeholders are only checked when the arguments of slot are rendered, e.g. `${A:+${B}}` needs B when A is set.
+func findMissingSlots(slot StrSlot, lookup func(string) (interface{}, bool)) []StrSlot {
+	missing := make([]StrSlot, 0)
+	value, exists := lookup(slot.Name)
+	if !exists && !slot.HasDefault() {
+		missing = append(missing, slot)
+	}
+	if !slotUsesArgs(slot, value, exists) {
+		return missing
+	}
+	for _, nested := range slot.Nested {
+		missing = append(missing, findMissingSlots(nested, lookup)...)
+	}
+	return missing
+}
+
+// slotUsesArgs reports whether the arguments of the placeholder are rendered with the value of its variable.
+func slotUsesArgs(slot StrSlot, value interface{}, exists bool) bool {
+	switch slot.Operator {
+	case "=", "-":
+		return !exists
+	case ":=", ":-":
+		return !exists || value == ""
+	case "+":
+		return exists
+	case ":+":
+		return exists && value != ""
+	case "?", ":?":
+		// the arguments are the error message of a missing variable
+		return false
+	}
+	// patterns of the substitution operators
+	return exists
+}
+
+func uniqueSlotNames(slots []StrSlot) []string {
+	if len(slots) < 1 {
+		return nil
+	}
+	names := make([]string, 0, len(slots))
+	seen := make(map[string]bool, len(slots))
+	for _, slot := range slots {
+		if !seen[slot.Name] {
+			seen[slot.Name] = true
+			names = append(names, slot.Name)
+		}
+	}
+	return names
+}
+
+func appendUnique(arr []string, s string) []string {
+	if containsString(arr, s) {
+		return arr
+	}
+	return append(arr, s)
+}
+
+func renderSlotError(err error, slot StrSlot) error {
+	return errors.Wrap(err, fmt.Sprintf("cannot render the placeholder %s at line %d, column %d", slot.Raw, slot.Line, slot.Column))
+}
+
+// escapeStrSlotValues renders the placeholders without replacement one by one and
+// puts the values escaped for their yaml context into replacements.
+func escapeStrSlotValues(tmpl string, slots []StrSlot, contexts []slotContext, replacements map[int]string, render func(StrSlot) (string, interface{}, error), syntax *strSlotSyntax) error {
+	values := make([]string, len(slots))
+	typed := make([]interface{}, len(slots))
+	for i, slot := range slots {
+		if _, ok := replacements[slot.Offset]; ok {
+			values[i] = slot.Raw
+			continue
+		}
+		value, typedValue, err := render(slot)
+		if err != nil {
+			return renderSlotError(err, syntax.original(slot))
+		}
+		values[i], typed[i] = value, typedValue
+	}
+
+	for i, slot := range slots {
+		if _, ok := replacements[slot.Offset]; ok {
+			continue
+		}
+		escaped, err := escapeSlotValue(slot, contexts[i], values[i], typed[i], outputByteBefore(tmpl, slots, values, i), outputByteAfter(tmpl, slots, values, i))
+		if err != nil {
+			if unsafeErr, ok := err.(*UnsafeStrSlotError); ok {
+				unsafeErr.Slot = syntax.original(unsafeErr.Slot)
+			}
+			return err
+		}
+		replacements[slot.Offset] = escaped
+	}
+	return nil
+}
+
+// outputByteBefore returns the byte before the i-th placeholder in the rendered output, line breaks are returned as ' '.
+func outputByteBefore(tmpl string, slots []StrSlot, values []string, i int) byte {
+	offset := slots[i].Offset
+	for ; offset > 0; i-- {
+		if i > 0 && slots[i-1].Offset+len(slots[i-1].Raw) == offset {
+			// adjacent to the previous placeholder
+			if v := values[i-1]; v != "" {
+				return lineBreakAsSpace(v[len(v)-1])
+			}
+			offset = slots[i-1].Offset
+			continue
+		}
+		return lineBreakAsSpace(tmpl[offset-1])
+	}
+	return ' '
+}
+
+// outputByteAfter returns the byte after the i-th placeholder in the rendered output, line breaks are returned as ' '.
+func outputByteAfter(tmpl string, slots []StrSlot, values []string, i int) byte {
+	end := slots[i].Offset + len(slots[i].Raw)
+	for ; end < len(tmpl); i++ {
+		if i+1 < len(slots) && slots[i+1].Offset == end {
+			// adjacent to the next placeholder
+			if v := values[i+1]; v != "" {
+				return lineBreakAsSpace(v[0])
+			}
+			end = slots[i+1].Offset + len(slots[i+1].Raw)
+			continue
+		}
+		return lineBreakAsSpace(tmpl[end])
+	}
+	return ' '
+}
+
+func lineBreakAsSpace(c byte) byte {
+	if c == '\n' || c == '\r' {
+		return ' '
+	}
+	return c
+}
+
+// replaceStrSlots replaces the placeholders having a replacement with the literal text,
+// the '$' of the replacements are escaped for envsubst. With keepEscapes the `$$` outside the placeholders
+// are doubled so that they are rendered as `$$`.
+func replaceStrSlots(tmpl string, slots []StrSlot, replacements map[int]string, keepEscapes bool) string {
+	if len(replacements) < 1 && !keepEscapes {
+		return tmpl
+	}
+	text := func(s string) string {
+		if keepEscapes {
+			return strings.ReplaceAll(s, "$$", "$$$$")
+		}
+		return s
+	}
+	b := strings.Builder{}
+	last := 0
+	for _, slot := range slots {
+		replacement, ok := replacements[slot.Offset]
+		b.WriteString(text(tmpl[last:slot.Offset]))
+		if ok {
+			b.WriteString(strings.ReplaceAll(replacement, "$", "$$"))
+		} else {
+			b.WriteString(slot.Raw)
+		}
+		last = slot.Offset + len(slot.Raw)
+	}
+	b.WriteString(text(tmpl[last:]))
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package structemplate
+
+import (
+	"strings"
+	"testing"
+)
+
+var strSlotTemplate string = `name: ${APP_NAME}
+namespace: ${NAMESPACE:=default}
+image: ${REGISTRY:-docker.io}/${IMAGE}
+owner: ${OWNER:-${TEAM}}
+escaped: $${NOT_A_SLOT}
+`
+
+func TestRenderStrSlotTemplate_MissingKeys(t *testing.T) {
+	result, missingKeys, err := RenderStrSlotTemplate(strSlotTemplate, map[string]interface{}{"APP_NAME": "demo"}, nil)
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	if strings.Join(missingKeys, ",") != "IMAGE,TEAM" {
+		t.Logf("Unexpected missing keys: %v", missingKeys)
+		t.FailNow()
+		return
+	}
+	if !strings.Contains(result, "image: docker.io/\n") || !strings.Contains(result, "escaped: ${NOT_A_SLOT}") {
+		t.Logf("Unexpected result: %s", result)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderStrSlotTemplate_Strict(t *testing.T) {
+	_, _, err := RenderStrSlotTemplateWithOptions(strSlotTemplate, map[string]interface{}{"APP_NAME": "demo"}, nil,
+		StrSlotRenderOptions{MissingKeyMode: StrSlotMissingKeyError})
+	missingErr, ok := err.(*MissingStrSlotError)
+	if !ok {
+		t.Logf("Unexpected error: %+v", err)
+		t.FailNow()
+		return
+	}
+	if len(missingErr.Slots) != 2 || missingErr.Slots[0].Position() != "3:31" || missingErr.Slots[1].Position() != "4:17" {
+		t.Logf("Unexpected missing slots: %s", missingErr.Error())
+		t.FailNow()
+		return
+	}
+
+	_, _, err = RenderStrSlotTemplateWithOptions(strSlotTemplate, map[string]interface{}{"APP_NAME": "demo", "IMAGE": "app", "OWNER": "me"}, nil,
+		StrSlotRenderOptions{MissingKeyMode: StrSlotMissingKeyError})
+	if err != nil {
+		t.Logf("Unexpected error: %+v", err)
+		t.FailNow()
+		return
+	}
+
+	// the nested variables are needed when the arguments are used
+	opts := StrSlotRenderOptions{MissingKeyMode: StrSlotMissingKeyError}
+	tmpl := "a: ${A:+${B}}\nc: ${C:-${D}}\n"
+	_, _, err = RenderStrSlotTemplateWithOptions(tmpl, map[string]interface{}{"A": "x", "C": ""}, nil, opts)
+	missingErr, ok = err.(*MissingStrSlotError)
+	if !ok || len(missingErr.Slots) != 2 || missingErr.Slots[0].Name != "B" || missingErr.Slots[1].Name != "D" {
+		t.Logf("Unexpected error: %+v", err)
+		t.FailNow()
+		return
+	}
+	result, _, err := RenderStrSlotTemplateWithOptions(tmpl, map[string]interface{}{"C": "c"}, nil, opts)
+	if err != nil || result != "a: \nc: c\n" {
+		t.Logf("Unexpected result: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderStrSlotTemplate_Keep(t *testing.T) {
+	result, _, err := RenderStrSlotTemplateWithOptions(strSlotTemplate, map[string]interface{}{"APP_NAME": "demo"}, nil,
+		StrSlotRenderOptions{MissingKeyMode: StrSlotMissingKeyKeep})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	expected := `name: demo
+namespace: default
+image: docker.io/${IMAGE}
+owner: ${OWNER:-${TEAM}}
+escaped: $${NOT_A_SLOT}
+`
+	if result != expected {
+		t.Logf("Unexpected result: %s", result)
+		t.FailNow()
+		return
+	}
+
+	// the second pass renders the kept placeholders, the escaped literal survives
+	values := map[string]interface{}{"IMAGE": "app", "TEAM": "ops", "NOT_A_SLOT": "INJECT"}
+	result, _, err = RenderStrSlotTemplate(result, values, nil)
+	if err != nil || result != "name: demo\nnamespace: default\nimage: docker.io/app\nowner: ops\nescaped: ${NOT_A_SLOT}\n" {
+		t.Logf("Unexpected result of the second pass: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+
+	opts := StrSlotRenderOptions{LeftDelim: "[[", RightDelim: "]]", MissingKeyMode: StrSlotMissingKeyKeep}
+	result, _, err = RenderStrSlotTemplateWithOptions("a: [[A]] \\[[X]] [[B]] $$ $\n", map[string]interface{}{"A": "1"}, nil, opts)
+	if err != nil || result != "a: 1 \\[[X]] [[B]] $$ $\n" {
+		t.Logf("Unexpected result with delimiters: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+	opts.MissingKeyMode = StrSlotMissingKeyEmpty
+	result, _, err = RenderStrSlotTemplateWithOptions(result, map[string]interface{}{"B": "2", "X": "INJECT"}, nil, opts)
+	if err != nil || result != "a: 1 [[X]] 2 $$ $\n" {
+		t.Logf("Unexpected result of the second pass with delimiters: %s, %+v", result, err)
+		t.FailNow()
+		return
+	}
+}
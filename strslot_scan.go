@@ -0,0 +1,333 @@
+package structemplate
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/drone/envsubst/v2/parse"
+	"github.com/pkg/errors"
+)
+
+// StrSlot is a `${...}` placeholder found in a StrSlot template.
+type StrSlot struct {
+	Name     string          `json:"name"`               // 变量名
+	Operator string          `json:"operator,omitempty"` // 替换操作符, 如 `:=`, `:-`, `^^`, 为空表示直接替换
+	Args     string          `json:"args,omitempty"`     // 操作符之后的原始参数文本, 对于默认值操作符即为默认值
+	Filters  []StrSlotFilter `json:"filters,omitempty"`  // `|` 之后的过滤器, 按顺序作用于变量值
+	Raw      string          `json:"raw"`                // 占位符原文
+	Nested   []StrSlot       `json:"nested,omitempty"`   // 参数中引用的其他变量
+
+	Offset int `json:"offset"` // 占位符在模板中的字节偏移
+	Line   int `json:"line"`   // 行号, 从1开始
+	Column int `json:"column"` // 列号(按字符计), 从1开始
+}
+
+// HasDefault reports whether the slot falls back to its Args when the variable has no value.
+func (s *StrSlot) HasDefault() bool {
+	switch s.Operator {
+	case "=", ":=", "-", ":-", "+", ":+":
+		return true
+	}
+	return false
+}
+
+// IsRequired reports whether the slot is declared as required with `${VAR:?message}`.
+func (s *StrSlot) IsRequired() bool {
+	return s.Operator == ":?" || s.Operator == "?"
+}
+
+// DefaultValue returns the default value of `${VAR:=default}`, `${VAR:-default}`, `${VAR=default}` and `${VAR-default}`.
+func (s *StrSlot) DefaultValue() (string, bool) {
+	switch s.Operator {
+	case "=", ":=", "-", ":-":
+		return s.Args, true
+	}
+	return "", false
+}
+
+// expression returns the placeholder without its filters, which can be evaluated by envsubst.
+func (s *StrSlot) expression() string {
+	if len(s.Filters) < 1 {
+		return s.Raw
+	}
+	if s.Operator == "#" {
+		return "${#" + s.Name + "}"
+	}
+	return "${" + s.Name + s.Operator + s.Args + "}"
+}
+
+// Position returns the `line:column` form of the slot position.
+func (s *StrSlot) Position() string {
+	return fmt.Sprintf("%d:%d", s.Line, s.Column)
+}
+
+// StrSlotVariable summarizes the usages of a variable in a StrSlot template.
+type StrSlotVariable struct {
+	Name       string    `json:"name"`                // 变量名
+	Default    string    `json:"default,omitempty"`   // 模板中声明的默认值, 取第一个声明了默认值的占位符
+	HasDefault bool      `json:"hasDefault"`          // 是否在模板中声明了默认值
+	Operators  []string  `json:"operators,omitempty"` // 使用过的替换操作符(去重)
+	Slots      []StrSlot `json:"slots"`               // 引用该变量的所有占位符, 包含嵌套在其他占位符参数中的
+}
+
+// FindStrSlots returns the top-level `${...}` placeholders of a StrSlot template in order of appearance.
+// Placeholders nested in the arguments of another placeholder are listed in its Nested field.
+func FindStrSlots(tmpl string) ([]StrSlot, error) {
+	return scanStrSlots(tmpl)
+}
+
+// FindStrSlotsWithOptions is FindStrSlots for templates using the delimiters of opts.
+// Offsets and positions of the placeholders refer to the original template.
+func FindStrSlotsWithOptions(tmpl string, opts StrSlotRenderOptions) ([]StrSlot, error) {
+	syntax, err := newStrSlotSyntax(opts)
+	if err != nil {
+		return nil, err
+	}
+	if syntax == nil {
+		return scanStrSlots(tmpl)
+	}
+	converted, err := syntax.convert(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := scanStrSlots(converted)
+	if err != nil {
+		return nil, err
+	}
+	return syntax.originalSlots(slots), nil
+}
+
+// AnalyzeStrSlotTemplate lists the variables referenced by a StrSlot template without rendering it.
+// Variables are returned in order of their first appearance, nested placeholders included.
+func AnalyzeStrSlotTemplate(tmpl string) ([]StrSlotVariable, error) {
+	return AnalyzeStrSlotTemplateWithOptions(tmpl, StrSlotRenderOptions{})
+}
+
+// AnalyzeStrSlotTemplateWithOptions is AnalyzeStrSlotTemplate for templates using the delimiters of opts.
+func AnalyzeStrSlotTemplateWithOptions(tmpl string, opts StrSlotRenderOptions) ([]StrSlotVariable, error) {
+	slots, err := FindStrSlotsWithOptions(tmpl, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make([]StrSlotVariable, 0)
+	varIdx := make(map[string]int)
+	var collect func(slots []StrSlot)
+	collect = func(slots []StrSlot) {
+		for _, slot := range slots {
+			idx, ok := varIdx[slot.Name]
+			if !ok {
+				idx = len(vars)
+				varIdx[slot.Name] = idx
+				vars = append(vars, StrSlotVariable{Name: slot.Name, Slots: make([]StrSlot, 0, 1)})
+			}
+			v := &vars[idx]
+			v.Slots = append(v.Slots, slot)
+			if def, ok := slot.DefaultValue(); ok && !v.HasDefault {
+				v.Default = def
+				v.HasDefault = true
+			}
+			if slot.Operator != "" && !containsString(v.Operators, slot.Operator) {
+				v.Operators = append(v.Operators, slot.Operator)
+			}
+			collect(slot.Nested)
+		}
+	}
+	collect(slots)
+	return vars, nil
+}
+
+func containsString(arr []string, s string) bool {
+	for _, e := range arr {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// scanStrSlots finds the top-level `${...}` placeholders of a template in order.
+// `$$` escapes a '$' the same way envsubst does.
+func scanStrSlots(tmpl string) ([]StrSlot, error) {
+	return scanStrSlotsAt(tmpl, 0, 1, 1)
+}
+
+func scanStrSlotsAt(tmpl string, baseOffset int, baseLine int, baseColumn int) ([]StrSlot, error) {
+	slots := make([]StrSlot, 0)
+	line, column := baseLine, baseColumn
+	for i := 0; i < len(tmpl); {
+		switch {
+		case strings.HasPrefix(tmpl[i:], "$$"):
+			i += 2
+			column += 2
+			continue
+		case strings.HasPrefix(tmpl[i:], "${"):
+			end := findSlotEnd(tmpl, i+2)
+			if end < 0 {
+				return nil, fmt.Errorf("missing closing brace of the placeholder at line %d, column %d", line, column)
+			}
+			slot, err := parseStrSlot(tmpl[i:end+1], baseOffset+i, line, column)
+			if err != nil {
+				return nil, err
+			}
+			slots = append(slots, *slot)
+			for _, r := range tmpl[i : end+1] {
+				line, column = advancePosition(r, line, column)
+			}
+			i = end + 1
+			continue
+		}
+		r, w := utf8.DecodeRuneInString(tmpl[i:])
+		line, column = advancePosition(r, line, column)
+		i += w
+	}
+	return slots, nil
+}
+
+func advancePosition(r rune, line int, column int) (int, int) {
+	if r == '\n' {
+		return line + 1, 1
+	}
+	return line, column + 1
+}
+
+// findSlotEnd returns the index of the '}' closing a placeholder whose content starts at start.
+// Quoted arguments of filters may contain braces, like `${NAME | replace "}" ""}`.
+func findSlotEnd(tmpl string, start int) int {
+	filters, _ := filterSeparatorAt(tmpl, start)
+	depth := 1
+	for i := start; i < len(tmpl); i++ {
+		switch {
+		case filters >= 0 && i > filters && (tmpl[i] == '"' || tmpl[i] == '\''):
+			if end := quotedArgEnd(tmpl, i); end > 0 {
+				i = end
+			}
+		case strings.HasPrefix(tmpl[i:], "$$"):
+			i++
+		case strings.HasPrefix(tmpl[i:], "${"):
+			depth++
+			i++
+		case tmpl[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// quotedArgEnd returns the index of the quote closing the filter argument starting at start, or -1.
+func quotedArgEnd(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			return i
+		case s[i] == '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+// parseStrSlot parses a single placeholder with the envsubst parser.
+func parseStrSlot(raw string, offset int, line int, column int) (*StrSlot, error) {
+	// `${NAME | filter args}`, the filters are not known to envsubst
+	head := raw
+	var filters []StrSlotFilter
+	idx, err := findFilterSeparator(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("cannot parse the placeholder %s at line %d, column %d", raw, line, column))
+	}
+	if idx > 0 {
+		if filters, err = parseStrSlotFilters(raw[idx+1 : len(raw)-1]); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("cannot parse the placeholder %s at line %d, column %d", raw, line, column))
+		}
+		head = strings.TrimRight(raw[:idx], " \t") + "}"
+	}
+
+	tree, err := parse.Parse(head)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("cannot parse the placeholder %s at line %d, column %d", raw, line, column))
+	}
+	node, ok := tree.Root.(*parse.FuncNode)
+	if !ok {
+		return nil, fmt.Errorf("cannot parse the placeholder %s at line %d, column %d", raw, line, column)
+	}
+
+	slot := &StrSlot{
+		Name:     node.Param,
+		Operator: node.Name,
+		Filters:  filters,
+		Raw:      raw,
+		Offset:   offset,
+		Line:     line,
+		Column:   column,
+	}
+	if head == "${#"+node.Param+"}" {
+		// length of the variable, `#` is the operator
+		slot.Operator = "#"
+		return slot, nil
+	}
+	argsStart := len("${") + len(node.Param) + len(node.Name)
+	if argsStart <= len(head)-1 {
+		slot.Args = head[argsStart : len(head)-1]
+		argsLine, argsColumn := line, column
+		for _, r := range head[:argsStart] {
+			argsLine, argsColumn = advancePosition(r, argsLine, argsColumn)
+		}
+		if slot.Nested, err = scanStrSlotsAt(slot.Args, offset+argsStart, argsLine, argsColumn); err != nil {
+			return nil, err
+		}
+	}
+	return slot, nil
+}
+
+// findFilterSeparator returns the index of the ` |` separating the filters of a placeholder from the variable name,
+// like `${NAME | lower}`, or -1 if the placeholder has no filter. Filters only follow the name directly,
+// a '|' in the arguments of an operator like `${MSG:-a | b}` is part of the arguments.
+func findFilterSeparator(raw string) (int, error) {
+	i, spaced := filterSeparatorAt(raw, len("${"))
+	if i < 0 || i >= len(raw)-1 {
+		return -1, nil
+	}
+	if !spaced {
+		return -1, fmt.Errorf("a space is needed before the '|' of the filters, like %s | %s",
+			raw[:i], strings.TrimSpace(raw[i+1:]))
+	}
+	return i, nil
+}
+
+// filterSeparatorAt returns the index of a '|' directly following the variable name of a placeholder whose
+// content starts at start, or -1, and whether spaces are between the name and the '|'.
+func filterSeparatorAt(s string, start int) (int, bool) {
+	i := start
+	if i < len(s) && s[i] == '#' {
+		i++
+	}
+	nameStart := i
+	for i < len(s) && isStrSlotNameChar(s[i]) {
+		i++
+	}
+	if i == nameStart {
+		return -1, false
+	}
+	spaces := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+		spaces++
+	}
+	if i < len(s) && s[i] == '|' {
+		return i, spaces > 0
+	}
+	return -1, false
+}
+
+// isStrSlotNameChar reports whether c may be part of a variable name, the same as envsubst.
+func isStrSlotNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
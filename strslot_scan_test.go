@@ -0,0 +1,87 @@
+package structemplate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeStrSlotTemplate(t *testing.T) {
+	vars, err := AnalyzeStrSlotTemplate(strSlotTemplate)
+	if err != nil {
+		t.Logf("Failed analyze template: %+v", err)
+		t.FailNow()
+		return
+	}
+	names := make([]string, 0, len(vars))
+	for _, v := range vars {
+		names = append(names, v.Name)
+	}
+	if strings.Join(names, ",") != "APP_NAME,NAMESPACE,REGISTRY,IMAGE,OWNER,TEAM" {
+		t.Logf("Unexpected variables: %v", names)
+		t.FailNow()
+		return
+	}
+
+	ns := vars[1]
+	if !ns.HasDefault || ns.Default != "default" || ns.Operators[0] != ":=" || ns.Slots[0].Position() != "2:12" {
+		t.Logf("Unexpected variable: %+v", ns)
+		t.FailNow()
+		return
+	}
+	owner := vars[4]
+	if owner.Default != "${TEAM}" || len(owner.Slots[0].Nested) != 1 {
+		t.Logf("Unexpected variable: %+v", owner)
+		t.FailNow()
+		return
+	}
+	team := vars[5]
+	if team.HasDefault || team.Slots[0].Position() != "4:17" || team.Slots[0].Offset != strings.Index(strSlotTemplate, "${TEAM}") {
+		t.Logf("Unexpected variable: %+v", team)
+		t.FailNow()
+		return
+	}
+}
+
+func TestAnalyzeStrSlotTemplate_Operators(t *testing.T) {
+	slots, err := FindStrSlots("a: ${A:?required}\nb: ${B^^}\nc: ${#C}\nd: ${D:1:2}\n")
+	if err != nil {
+		t.Logf("Failed find slots: %+v", err)
+		t.FailNow()
+		return
+	}
+	if len(slots) != 4 || !slots[0].IsRequired() || slots[1].Operator != "^^" || slots[2].Operator != "#" || slots[3].Operator != ":" || slots[3].Args != "1:2" {
+		t.Logf("Unexpected slots: %+v", slots)
+		t.FailNow()
+		return
+	}
+
+	if _, err := FindStrSlots("a: ${A"); err == nil {
+		t.Logf("Unclosed placeholder should fail")
+		t.FailNow()
+		return
+	}
+}
+
+func TestCheckStrSlotParams(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "APP_NAME", ParamType: ParamTypeStrSlot},
+		{ParamCode: "IMAGE", ParamType: ParamTypeStrSlot},
+		{ParamCode: "REPLICAS", ParamType: ParamTypeStrSlot},
+		{ParamCode: "OWNER", ParamType: ParamTypeJsonPath},
+	}
+	result, err := CheckStrSlotParams(strSlotTemplate, params)
+	if err != nil {
+		t.Logf("Failed check params: %+v", err)
+		t.FailNow()
+		return
+	}
+	undefined := make([]string, 0)
+	for _, v := range result.Undefined {
+		undefined = append(undefined, v.Name)
+	}
+	if !result.HasProblems() || strings.Join(undefined, ",") != "NAMESPACE,REGISTRY,OWNER,TEAM" || strings.Join(result.Unused, ",") != "REPLICAS" {
+		t.Logf("Unexpected result: %+v, %v", undefined, result.Unused)
+		t.FailNow()
+		return
+	}
+}
@@ -0,0 +1,86 @@
+package structemplate
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const typedSlotMarker = "__structemplate_typed_slot_"
+
+// typedSlotMarkerPrefix returns a marker prefix not contained in the template.
+func typedSlotMarkerPrefix(tmpl string) string {
+	prefix := typedSlotMarker
+	for strings.Contains(tmpl, prefix) {
+		prefix += "_"
+	}
+	return prefix
+}
+
+// slotUsesValue reports whether the placeholder renders the value of its variable rather than its arguments.
+func slotUsesValue(slot StrSlot, value interface{}) bool {
+	switch slot.Operator {
+	case "", "=", "-":
+		return true
+	case ":=", ":-":
+		// empty strings are replaced by the default value
+		return value != ""
+	}
+	return false
+}
+
+// RenderStrSlotTypedTemplate renders a yaml/json StrSlot template and decodes the result like LoadManifests.
+// Placeholders forming a whole plain scalar, like `replicas: ${REPLICAS}`, are replaced after decoding with
+// the typed param values: an int stays an int, an array becomes a sequence and a string stays a string
+// whatever its content (`"08"`, `yes`, `1e3`). Placeholders inside quotes, block scalars, mapping keys or a
+// larger scalar, and placeholders using their default value are rendered as text.
+func RenderStrSlotTypedTemplate(tmpl string, valuesMapOfInterface map[string]interface{}, valuesMapOfString map[string]string, opts StrSlotRenderOptions) (*ManifestObjects, []string, error) {
+	typedValues := make(map[string]interface{})
+	rendered, missingKeys, err := renderStrSlotTemplate(tmpl, valuesMapOfInterface, valuesMapOfString, opts, typedValues)
+	if err != nil {
+		return nil, missingKeys, err
+	}
+	manifestObjs, err := LoadManifestsFromString(rendered)
+	if err != nil {
+		return nil, missingKeys, errors.Wrap(err, "cannot decode the rendered manifest")
+	}
+
+	for marker, value := range typedValues {
+		if typedValues[marker], err = ToJSONValue(value); err != nil {
+			return nil, missingKeys, errors.Wrap(err, "cannot convert the param value")
+		}
+	}
+	// the GVKs may contain typed placeholders, group the objects again
+	result := &ManifestObjects{
+		Objects:    make([]*unstructured.Unstructured, 0, len(manifestObjs.Objects)),
+		ObjectsMap: make(map[schema.GroupVersionKind][]*unstructured.Unstructured),
+	}
+	for _, obj := range manifestObjs.Objects {
+		replaceTypedSlotMarkers(obj.Object, typedValues)
+		if err := result.add(obj); err != nil {
+			return nil, missingKeys, err
+		}
+	}
+	return result, missingKeys, nil
+}
+
+// replaceTypedSlotMarkers replaces the marker strings in the object with copies of the typed values.
+func replaceTypedSlotMarkers(value interface{}, typedValues map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if typed, ok := typedValues[v]; ok {
+			return DeepCopyJSONValue(typed)
+		}
+	case map[string]interface{}:
+		for k, elem := range v {
+			v[k] = replaceTypedSlotMarkers(elem, typedValues)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = replaceTypedSlotMarkers(elem, typedValues)
+		}
+	}
+	return value
+}
@@ -0,0 +1,90 @@
+package structemplate
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var typedTemplate string = `apiVersion: apps/v1
+kind: ${KIND}
+metadata:
+  name: ${NAME}
+  labels:
+    version: "${VERSION}"
+  annotations:
+    ${KEY}: value
+spec:
+  replicas: ${REPLICAS}
+  zip: ${ZIP}
+  enabled: ${ENABLED}
+  args: ${ARGS}
+  flow: [${ZIP}, b]
+  image: ${REGISTRY}/app
+  timeout: ${TIMEOUT:-30}
+  name: ${NAME | upper}
+`
+
+func TestRenderStrSlotTypedTemplate(t *testing.T) {
+	values := map[string]interface{}{
+		"KIND":     "Deployment",
+		"NAME":     "demo",
+		"VERSION":  "1.0",
+		"KEY":      "note",
+		"REPLICAS": 3,
+		"ZIP":      "08",
+		"ENABLED":  "yes",
+		"ARGS":     []string{"--port", "1e3"},
+		"REGISTRY": "docker.io",
+	}
+	objs, _, err := RenderStrSlotTypedTemplate(typedTemplate, values, nil, StrSlotRenderOptions{})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	deploys := objs.ObjectsMap[schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}]
+	if len(deploys) != 1 {
+		t.Logf("Unexpected objects: %+v", objs.ObjectsMap)
+		t.FailNow()
+		return
+	}
+	obj := deploys[0]
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	expected := map[string]interface{}{
+		"replicas": int64(3),
+		"zip":      "08",
+		"enabled":  "yes",
+		"args":     []interface{}{"--port", "1e3"},
+		"flow":     []interface{}{"08", "b"},
+		"image":    "docker.io/app",
+		"timeout":  int64(30),
+		"name":     "DEMO",
+	}
+	if !reflect.DeepEqual(spec, expected) || obj.GetLabels()["version"] != "1.0" || obj.GetAnnotations()["note"] != "value" {
+		t.Logf("Unexpected object: %+v", obj.Object)
+		t.FailNow()
+		return
+	}
+}
+
+func TestTemplateRender_TypedScalars(t *testing.T) {
+	tmpl := NewTemplate("typed", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: demo\ndata:\n  port: ${PORT}\n  list: ${LIST}\n", []TemplateDynamicParam{
+		{ParamCode: "PORT", ParamType: ParamTypeStrSlot, Default: "0800"},
+	})
+	tmpl.StrSlotOptions.TypedScalars = true
+	objs, err := tmpl.Render(ParamValuesMap{"LIST": []interface{}{1, "a"}})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	data, _, _ := unstructured.NestedMap(objs[0].Object, "data")
+	if data["port"] != "0800" || !reflect.DeepEqual(data["list"], []interface{}{int64(1), "a"}) {
+		t.Logf("Unexpected data: %+v", data)
+		t.FailNow()
+		return
+	}
+}
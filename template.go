@@ -0,0 +1,263 @@
+package structemplate
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Template bundles a manifest template with the dynamic params defined on it.
+// The manifest may contain multiple yaml/json documents and StrSlot placeholders,
+// JsonPath params are applied to the decoded objects.
+type Template struct {
+	Name        string            `json:"name"`        // 模板名称
+	Version     string            `json:"version"`     // 模板版本
+	Description string            `json:"description"` // 模板说明
+	Labels      map[string]string `json:"labels,omitempty"`
+
+	Manifest string                 `json:"manifest"`        // 模板内容, 支持多文档yaml或json
+	Params   []TemplateDynamicParam `json:"params"`          // 模板中定义的动态参数
+	Rules    []ValidationRule       `json:"rules,omitempty"` // 渲染前检查的模板级校验规则
+
+	StrSlotOptions  StrSlotRenderOptions `json:"strSlotOptions,omitempty"` // StrSlot参数的渲染选项
+	GenerateOptions ValueGenerateOptions `json:"-"`                        // 自动生成参数值的选项, 渲染时设置, 不序列化
+}
+
+// NewTemplate creates a Template from a manifest string and its param definitions.
+func NewTemplate(name string, manifest string, params []TemplateDynamicParam) *Template {
+	return &Template{
+		Name:     name,
+		Manifest: manifest,
+		Params:   params,
+	}
+}
+
+// Render renders the template with the values map.
+// Params hidden by their VisibleIf condition are dropped first with their values, the visibility is resolved once
+// with the values provided and is not changed by generated or computed values. Then the RequiredIf, DependsOn and
+// ConflictsWith conditions are checked, see ActiveParams and CheckParamConditions. Values of params with a Generator
+// are generated when not provided, see GenerateValues.
+// Values are validated and coerced against the ValueDataType of params, see ValidateParamValues, then computed params
+// are evaluated and the Rules of the template are checked, see EvaluateComputedParams and CheckValidationRules.
+// StrSlot params are substituted in the manifest text first, then the result is decoded
+// and JsonPath params are applied to the decoded objects.
+// With StrSlotOptions.TypedScalars whole-scalar placeholders keep the types of the values, see RenderStrSlotTypedTemplate.
+// The rendered objects are returned in the order they appear in the manifest.
+func (t *Template) Render(values ParamValuesMap) ([]*unstructured.Unstructured, error) {
+	params, visible, values, err := t.activeParams(values)
+	if err != nil {
+		return nil, err
+	}
+	if values, err = GenerateParamValues(params, values, t.GenerateOptions); err != nil {
+		return nil, err
+	}
+	if err := checkParamConditions(t.Params, values, visible); err != nil {
+		return nil, err
+	}
+	values, err = ValidateParamValues(params, values)
+	if err != nil {
+		return nil, err
+	}
+	values, err = EvaluateComputedParams(params, values)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckValidationRules(t.Rules, params, values); err != nil {
+		return nil, err
+	}
+
+	manifestObjs, err := t.renderManifestObjects(params, values)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := renderJsonPathParams(manifestObjs.ObjectsMap, params, values); err != nil {
+		return nil, err
+	}
+	return manifestObjs.Objects, nil
+}
+
+// Validate checks the values against the template without returning the rendered objects, and reports all the
+// problems found at once: invalid conditions and violated RequiredIf, DependsOn and ConflictsWith conditions
+// (see CheckParamConditions), invalid values and invalid defaults of params without value (see ValidateParamValues), failing computed params and rules
+// (see EvaluateComputedParams and CheckValidationRules), missing and unsafe StrSlot values,
+// missing required JsonPath params and inject targets matching no object (see ValidateJsonPathParams).
+// The returned error is a ParamValueErrors when all the problems are param problems,
+// other errors such as an invalid manifest are returned as they are.
+func (t *Template) Validate(values ParamValuesMap) error {
+	errs := make(ParamValueErrors, 0)
+	params, visible, values, err := t.activeParams(values)
+	if err != nil {
+		errs = append(errs, err.(ParamValueErrors)...)
+	}
+	generated, err := GenerateParamValues(params, values, t.GenerateOptions)
+	if err != nil {
+		errs = append(errs, err.(ParamValueErrors)...)
+	} else {
+		values = generated
+	}
+	if err := checkParamConditions(t.Params, values, visible); err != nil {
+		for _, e := range err.(ParamValueErrors) {
+			errs = appendConditionError(errs, e)
+		}
+	}
+	if err := validateParamDefaults(params, values); err != nil {
+		errs = append(errs, err.(ParamValueErrors)...)
+	}
+
+	coerced, err := ValidateParamValues(params, values)
+	if err != nil {
+		valueErrs, ok := err.(ParamValueErrors)
+		if !ok {
+			return err
+		}
+		errs = append(errs, valueErrs...)
+		coerced = values
+	} else {
+		// expressions are evaluated with valid values only
+		computed, err := EvaluateComputedParams(params, coerced)
+		if err != nil {
+			exprErrs, ok := err.(ParamValueErrors)
+			if !ok {
+				return err
+			}
+			errs = append(errs, exprErrs...)
+		} else {
+			coerced = computed
+			if err := CheckValidationRules(t.Rules, params, coerced); err != nil {
+				ruleErrs, ok := err.(ParamValueErrors)
+				if !ok {
+					return err
+				}
+				errs = append(errs, ruleErrs...)
+			}
+		}
+	}
+
+	manifestObjs, err := t.renderManifestObjects(params, coerced)
+	if err != nil {
+		switch e := errors.Cause(err).(type) {
+		case *MissingStrSlotError:
+			for _, slot := range e.Slots {
+				errs = append(errs, &ParamValueError{Code: ErrCodeStrSlotMissing, ParamCode: slot.Name,
+					Reason: fmt.Sprintf("line %d, column %d", slot.Line, slot.Column)})
+			}
+		case *UnsafeStrSlotError:
+			errs = append(errs, &ParamValueError{Code: ErrCodeUnsafeValue, ParamCode: e.Slot.Name,
+				Reason: fmt.Sprintf("line %d, column %d, %s: %s", e.Slot.Line, e.Slot.Column, e.Context, e.Reason)})
+		default:
+			return err
+		}
+	}
+	var objsMap map[schema.GroupVersionKind][]*unstructured.Unstructured
+	if manifestObjs != nil {
+		objsMap = manifestObjs.ObjectsMap
+	}
+	if err := validateJsonPathParams(objsMap, params, coerced); err != nil {
+		errs = append(errs, err.(ParamValueErrors)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// GenerateValues returns a copy of values with the generated values of the visible params with a Generator,
+// see GenerateParamValues. Store the result to render the template again with the same generated values.
+func (t *Template) GenerateValues(values ParamValuesMap) (ParamValuesMap, error) {
+	params, _, values, err := t.activeParams(values)
+	if err != nil {
+		return nil, err
+	}
+	return GenerateParamValues(params, values, t.GenerateOptions)
+}
+
+// activeParams returns the params not hidden by their VisibleIf condition, the visibility of t.Params
+// (see resolveParamVisibility) and the values without the hidden params.
+// The visibility is resolved once before values are generated and reused by the later steps of rendering.
+// With invalid conditions the params resolved are returned with the error.
+func (t *Template) activeParams(values ParamValuesMap) ([]TemplateDynamicParam, map[int]bool, ParamValuesMap, error) {
+	visible, err := resolveParamVisibility(t.Params, values)
+	params := make([]TemplateDynamicParam, 0, len(t.Params))
+	active := make(map[string]bool, len(t.Params))
+	for i := range t.Params {
+		if visible[i] {
+			params = append(params, t.Params[i])
+			active[t.Params[i].ParamCode] = true
+		}
+	}
+	result := make(ParamValuesMap, len(values))
+	for k, v := range values {
+		if _, ok := t.findParam(k); ok && !active[k] {
+			continue
+		}
+		result[k] = v
+	}
+	return params, visible, result, err
+}
+
+func (t *Template) findParam(code string) (*TemplateDynamicParam, bool) {
+	for i := range t.Params {
+		if t.Params[i].ParamCode == code {
+			return &t.Params[i], true
+		}
+	}
+	return nil, false
+}
+
+// renderManifestObjects renders the StrSlot params of the template and decodes the result.
+func (t *Template) renderManifestObjects(params []TemplateDynamicParam, values ParamValuesMap) (*ManifestObjects, error) {
+	if t.StrSlotOptions.TypedScalars {
+		manifestObjs, _, err := RenderStrSlotTypedTemplate(t.Manifest, strSlotValues(params, values), nil, t.StrSlotOptions)
+		return manifestObjs, err
+	}
+	rendered, err := t.renderStrSlots(params, values)
+	if err != nil {
+		return nil, err
+	}
+	manifestObjs, err := LoadManifestsFromString(rendered)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot decode the rendered manifest")
+	}
+	return manifestObjs, nil
+}
+
+// RenderStrSlots renders the StrSlot params of the template and returns the rendered manifest text.
+// Defaults of StrSlot params are used for the values not provided, params hidden by their VisibleIf condition have no value.
+func (t *Template) RenderStrSlots(values ParamValuesMap) (string, error) {
+	params, _, values, err := t.activeParams(values)
+	if err != nil {
+		return "", err
+	}
+	return t.renderStrSlots(params, values)
+}
+
+func (t *Template) renderStrSlots(params []TemplateDynamicParam, values ParamValuesMap) (string, error) {
+	result, _, err := RenderStrSlotTemplateWithOptions(t.Manifest, strSlotValues(params, values), nil, t.StrSlotOptions)
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// strSlotValues merges the values with the defaults of StrSlot params.
+func strSlotValues(params []TemplateDynamicParam, values ParamValuesMap) map[string]interface{} {
+	valuesMap := make(map[string]interface{}, len(values))
+	for _, p := range params {
+		if p.ParamType == ParamTypeStrSlot && p.Default != nil {
+			valuesMap[p.ParamCode] = p.Default
+		}
+	}
+	for k, v := range values {
+		valuesMap[k] = v
+	}
+	return valuesMap
+}
+
+// CheckStrSlotParams cross-checks the StrSlot variables referenced in the manifest with the params of the template.
+func (t *Template) CheckStrSlotParams() (*StrSlotParamsCheckResult, error) {
+	return checkStrSlotParams(t.Manifest, t.Params, t.StrSlotOptions)
+}
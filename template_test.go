@@ -0,0 +1,67 @@
+package structemplate
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var templateManifest string = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ${APP_NAME}-config
+  namespace: ${NAMESPACE:=default}
+data:
+  key: value
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ${APP_NAME}
+spec:
+  replicas: 1
+`
+
+func TestTemplateRender(t *testing.T) {
+	tmpl := NewTemplate("app", templateManifest, []TemplateDynamicParam{
+		{
+			ParamCode: "APP_NAME",
+			ParamType: ParamTypeStrSlot,
+			Default:   "demo",
+		},
+		{
+			ParamCode: "REPLICAS",
+			ParamType: ParamTypeJsonPath,
+			ValueInjectTargets: []JsonPathParamTarget{
+				{
+					TargetGVK:     schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+					ParamJsonPath: ".spec.replicas",
+				},
+			},
+		},
+	})
+
+	objs, err := tmpl.Render(ParamValuesMap{"REPLICAS": int64(3)})
+	if err != nil {
+		t.Logf("Failed render template: %+v", err)
+		t.FailNow()
+		return
+	}
+	if len(objs) != 2 {
+		t.Logf("Unexpected objects count: %d", len(objs))
+		t.FailNow()
+		return
+	}
+	if objs[0].GetName() != "demo-config" || objs[0].GetNamespace() != "default" {
+		t.Logf("Unexpected ConfigMap metadata: %s/%s", objs[0].GetNamespace(), objs[0].GetName())
+		t.FailNow()
+		return
+	}
+	replicas, err := GetValueOfNestedField(objs[1].Object, ".spec.replicas")
+	if err != nil || replicas != int64(3) {
+		t.Logf("Unexpected replicas: %v, %v", replicas, err)
+		t.FailNow()
+		return
+	}
+}
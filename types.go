@@ -1,6 +1,7 @@
 package structemplate
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -15,30 +16,171 @@ type TemplateDynamicParam struct {
 	Brief     string `json:"brief"`     // 参数解释
 
 	FunctionScope      string                `json:"functionScope"` // 作用范围 设定系统参数或用户可自定义
-	ParamType          string                `json:"paramType"`     // StrSlot, JsonPath  支持两种动态参数设置方式. 基于字符串替换的StrSlot和自定义JsonPath
+	ParamType          string                `json:"paramType"`     // StrSlot, JsonPath, JsonPatch  支持三种动态参数设置方式. 基于字符串替换的StrSlot, 自定义JsonPath和JSON Patch(RFC 6902)
 	ValueInjectTargets []JsonPathParamTarget `json:"valueInjectTargets"`
 	Optional           bool                  `json:"optional"` // 是否为可选参数
 	Default            interface{}           `json:"default"`
 
-	AvailableOptions []interface{} `json:"availableOptions"` // 预设可选值
-	Customizable     bool          `json:"customizable"`     // 是否允许用户自定义。为false时仅支持设定AvailableOptions中预设的值
-	ValueDataType    string        `json:"dataType"`         // int, string, float, boolean, object, array[string] 当前仅用于类型提示
+	AvailableOptions []interface{} `json:"availableOptions"`  // 预设可选值
+	Options          []ParamOption `json:"options,omitempty"` // 带显示名称和说明的预设可选值, 与AvailableOptions一同生效
+	Customizable     bool          `json:"customizable"`      // 是否允许用户自定义。为false时仅支持设定AvailableOptions和Options中预设的值
+	ValueDataType    string        `json:"dataType"`          // int, string, float, boolean, object, array, array[string] 渲染前按此类型校验并转换参数值, 为空时不校验
+
+	Constraints *ParamConstraints `json:"constraints,omitempty"` // 渲染前检查的参数值约束
+
+	// 参数之间的条件, 见ActiveParams和CheckParamConditions
+	VisibleIf     *ParamCondition `json:"visibleIf,omitempty"`     // 参数生效的条件, 不满足时参数被隐藏: 忽略其值且不注入默认值
+	RequiredIf    *ParamCondition `json:"requiredIf,omitempty"`    // 满足条件时参数必须有值
+	DependsOn     []string        `json:"dependsOn,omitempty"`     // 设定了该参数时必须同时有值的参数
+	ConflictsWith []string        `json:"conflictsWith,omitempty"` // 不能与该参数同时设定的参数
+
+	Expression string `json:"expression,omitempty"` // CEL表达式, 不为空时为计算参数, 其值由表达式根据其他参数计算得出, 见EvaluateComputedParams
+
+	Generator *ValueGenerator `json:"generator,omitempty"` // 未提供值时自动生成参数值, 如密码、UUID、密钥, 见GenerateParamValues
 
 	// 对于jsonPath类型参数，处理对象和数组的方式
 	AppendArray bool   `json:"appendArray"` // 当JsonPath指向一个数组类型时, 进行替换还是追加
 	MapKey      string `json:"mapKey"`      // 当JsonPath指向目标为Map类型时，将在此map中增加一个KV对，此值不为空时表示中增加的KV对中的key
+
+	// 对于JsonPatch类型参数, 应用于ValueInjectTargets选中对象的JSON Patch文档, 其中的`${ParamCode}`占位符将被替换为参数值
+	JsonPatch []JsonPatchOperation `json:"jsonPatch,omitempty"`
+
+	InsertPosition *ArrayInsertPosition `json:"insertPosition,omitempty"` // AppendArray为true时，指定插入数组的位置，为空时追加到末尾
+
+	InjectMode    string `json:"injectMode,omitempty"`    // JsonPath参数的注入方式，为空时按AppendArray和MapKey处理. Remove, Merge, StrategicMerge
+	IgnoreMissing bool   `json:"ignoreMissing,omitempty"` // Remove模式下目标字段不存在时不报错
+}
+
+// ParamOption is a preset value of a param displayed in UI pickers.
+type ParamOption struct {
+	Value       interface{} `json:"value"`
+	Label       string      `json:"label,omitempty"`       // 显示名称, 为空时显示值本身
+	Description string      `json:"description,omitempty"` // 选项说明
+}
+
+// ParamConstraints declares the constraints checked on a param value before rendering, see CheckParamConstraints.
+// String and number constraints of array values are checked on every element.
+type ParamConstraints struct {
+	Pattern     string        `json:"pattern,omitempty"`     // 字符串须匹配的正则表达式(RE2语法)
+	Minimum     *float64      `json:"minimum,omitempty"`     // 数值最小值(含)
+	Maximum     *float64      `json:"maximum,omitempty"`     // 数值最大值(含)
+	MinLength   *int          `json:"minLength,omitempty"`   // 字符串最小长度, 按字符计
+	MaxLength   *int          `json:"maxLength,omitempty"`   // 字符串最大长度, 按字符计
+	MinItems    *int          `json:"minItems,omitempty"`    // 数组最少元素个数
+	MaxItems    *int          `json:"maxItems,omitempty"`    // 数组最多元素个数
+	UniqueItems bool          `json:"uniqueItems,omitempty"` // 数组元素不可重复
+	Enum        []interface{} `json:"enum,omitempty"`        // 允许的值
+	Format      string        `json:"format,omitempty"`      // 字符串格式: dns1123Label, dns1123Subdomain, labelValue, quantity, image, cidr, ip, port
+}
+
+// ValidationRule is a CEL expression checked on the param values of a whole template, see CheckValidationRules.
+// Params are referenced by their codes like `REPLICAS <= MAX_REPLICAS` or through `self` like `self.REPLICAS`.
+type ValidationRule struct {
+	Rule      string `json:"rule"`                // 返回bool的CEL表达式
+	Message   string `json:"message,omitempty"`   // 规则不满足时的错误信息, 为空时使用规则本身
+	ParamCode string `json:"paramCode,omitempty"` // 规则不满足时错误关联的参数
+}
+
+// ValueGenerator generates the value of a param when none is provided, see GenerateParamValues.
+type ValueGenerator struct {
+	Kind    string `json:"kind"`              // password, token, uuid, sshKey, tlsCert
+	Length  int    `json:"length,omitempty"`  // password的字符数(默认16), token的字节数(默认32)
+	Charset string `json:"charset,omitempty"` // password使用的字符集, 为空时使用大小写字母和数字
+
+	// 以下仅用于tlsCert
+	CommonName string   `json:"commonName,omitempty"` // 证书CN
+	DNSNames   []string `json:"dnsNames,omitempty"`   // 证书SAN中的域名
+	ValidDays  int      `json:"validDays,omitempty"`  // 证书有效天数, 默认365
 }
 
+// Kinds of ValueGenerator
+const (
+	GeneratorPassword = "password" // random string of Length characters of Charset
+	GeneratorToken    = "token"    // hex string of Length random bytes
+	GeneratorUUID     = "uuid"     // RFC 4122 version 4 UUID
+	// GeneratorSSHKey generates an ed25519 key pair as an object with `privateKey` in OpenSSH format
+	// and `publicKey` in authorized_keys format.
+	GeneratorSSHKey = "sshKey"
+	// GeneratorTLSCert generates a self-signed ECDSA P-256 certificate as an object with `tls.crt` and `tls.key`
+	// in PEM format, the keys of a kubernetes.io/tls Secret.
+	GeneratorTLSCert = "tlsCert"
+)
+
+// ParamCondition is a condition on the values of other params. A leaf condition tests the value of Param,
+// which is the provided value or the default, and has no value when the param is hidden:
+//   - Equals: the value equals Equals
+//   - NotEquals: the value does not equal NotEquals
+//   - In: the value is one of In
+//   - Exists: the param has a value (not nil nor empty string) when true, or has no value when false
+//   - none of the above: the param has a value
+//
+// AllOf, AnyOf and Not combine conditions, all the parts set in a condition must hold.
+type ParamCondition struct {
+	Param     string        `json:"param,omitempty"`     // 条件引用的参数ParamCode
+	Equals    interface{}   `json:"equals,omitempty"`    // 参数值等于该值
+	NotEquals interface{}   `json:"notEquals,omitempty"` // 参数值不等于该值
+	In        []interface{} `json:"in,omitempty"`        // 参数值为其中之一
+	Exists    *bool         `json:"exists,omitempty"`    // 参数是否有值
+
+	AllOf []ParamCondition `json:"allOf,omitempty"` // 全部满足
+	AnyOf []ParamCondition `json:"anyOf,omitempty"` // 满足其一
+	Not   *ParamCondition  `json:"not,omitempty"`   // 不满足
+}
+
+// Formats of ParamConstraints
+const (
+	FormatDNS1123Label     = "dns1123Label"
+	FormatDNS1123Subdomain = "dns1123Subdomain"
+	FormatLabelValue       = "labelValue"
+	FormatQuantity         = "quantity" // resource quantity like `500m`, `1Gi`
+	FormatImage            = "image"    // container image reference like `docker.io/library/nginx:1.25`
+	FormatCIDR             = "cidr"
+	FormatIP               = "ip"
+	FormatPort             = "port" // port number 1-65535
+)
+
 type JsonPathParamTarget struct {
 	TargetGVK           schema.GroupVersionKind `json:"targetGVK,omitempty"`           // 对于JsonPath类型参数，指定要设置的目标模板对象, 若存在多个同种对象,需要增加label来标识
 	ParamJsonPath       string                  `json:"paramJsonPath,omitempty"`       // .param1.param-sub1
 	ObjectLabelSelector map[string]string       `json:"objectDistinctLabel,omitempty"` // 用于区分同一个模板中同一种GVK定义的多个不同对象
+
+	// 以下选择条件与ObjectLabelSelector同时生效(AND)，为空时不参与筛选
+	ObjectName               string                            `json:"objectName,omitempty"`               // 按metadata.name精确匹配
+	ObjectNamespace          string                            `json:"objectNamespace,omitempty"`          // 按metadata.namespace精确匹配
+	ObjectAnnotationSelector map[string]string                 `json:"objectAnnotationSelector,omitempty"` // 按annotations精确匹配
+	ObjectLabelExpressions   []metav1.LabelSelectorRequirement `json:"objectLabelExpressions,omitempty"`   // 基于集合的label表达式: In, NotIn, Exists, DoesNotExist
 }
 
 // Dynamic param values type
 type ParamValuesMap map[string]interface{}
 
 const (
-	ParamTypeStrSlot  = "StrSlot"
-	ParamTypeJsonPath = "JsonPath"
+	ParamTypeStrSlot   = "StrSlot"
+	ParamTypeJsonPath  = "JsonPath"
+	ParamTypeJsonPatch = "JsonPatch"
+)
+
+// Value data types of params, see CoerceParamValue
+const (
+	DataTypeInt     = "int"
+	DataTypeFloat   = "float"
+	DataTypeBoolean = "boolean"
+	DataTypeString  = "string"
+	DataTypeObject  = "object"
+	// DataTypeArray is an array of any elements, `array[<type>]` like `array[string]` checks the type of the elements.
+	DataTypeArray = "array"
+)
+
+// Inject modes of JsonPath params
+const (
+	// InjectModeRemove removes the fields located by the json path. The param value works as a switch,
+	// `false` skips the removal while any other value removes the fields.
+	InjectModeRemove = "Remove"
+	// InjectModeMerge deep merges the param value into the field located by the json path
+	// following JSON merge patch (RFC 7386) semantics, null values delete the keys.
+	InjectModeMerge = "Merge"
+	// InjectModeStrategicMerge merges the param value like a Kubernetes strategic merge patch, elements of
+	// known lists (containers, env, ports, volumes...) replace the elements with the same key instead of
+	// being duplicated. See RegisterStrategicMergeKey for lists of custom resources.
+	InjectModeStrategicMerge = "StrategicMerge"
 )
@@ -10,6 +10,9 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ParseKeyPath splits a `.spec.name1.name2` style key path by '.'.
+//
+// Deprecated: keys containing '.' cannot be represented, use CompileJsonPath instead.
 func ParseKeyPath(keyPath string) []string {
 	t1 := strings.Trim(keyPath, "$")
 	t1 = strings.Trim(t1, ".")
@@ -17,40 +20,21 @@ func ParseKeyPath(keyPath string) []string {
 }
 
 // GetValueOfNestedField gets the value of field specified by `jsonPath` from the target object.
+// See JsonPath for the supported syntax.
 func GetValueOfNestedField(object map[string]interface{}, jsonPath string) (interface{}, error) {
 	if len(jsonPath) < 1 || jsonPath == "." {
 		return DeepCopyJSONValue(object), nil
 	}
 
-	paths := ParseKeyPath(jsonPath)
-	var field interface{} = DeepCopyJSONValue(object)
-
-	var tracedPath string = ""
-	for i := 0; i < len(paths); i++ {
-		key := paths[i]
-		tracedPath += "." + key
-		switch reflect.TypeOf(field).Kind() {
-		case reflect.Slice:
-			idx, err := ParseJsonPathArrayIndex(key)
-			if err != nil {
-				return nil, errors.Wrap(err, "cannot parse the index of array field: "+tracedPath)
-			}
-			tmpField := field.([]interface{})
-			if len(tmpField) <= int(idx) {
-				return nil, errors.New(fmt.Sprintf("array field index out of bounds: %d of %s", idx, tracedPath))
-			}
-			field = tmpField[idx]
-		case reflect.Map:
-			tmpField := field.(map[string]interface{})
-			field = tmpField[key]
-		default:
-			return nil, errors.New("field does not exist: " + tracedPath)
-		}
+	path, err := compileJsonPathCached(jsonPath)
+	if err != nil {
+		return nil, err
 	}
-	return field, nil
+	return path.Get(object)
 }
 
 // SetNestedField sets a value in the structure of object
+// @Param jsonPath: json path of the field to set, see JsonPath for the supported syntax. Missing parent fields are created automatically.
 // @Param appendArray: the element to operating is an array and the value should be appended in the array
 // @Param value: the value to inject. When append array is true, and value is an array, the elements in `value` will all be appended to the template.
 func SetNestedField(object map[string]interface{}, jsonPath string, value interface{}, appendArray bool) error {
@@ -58,119 +42,60 @@ func SetNestedField(object map[string]interface{}, jsonPath string, value interf
 		return errors.New("param jsonPath is empty")
 	}
 
-	paths := ParseKeyPath(jsonPath)
-	var jumper interface{} = object
-	var jumperBackNode interface{} = object
-
-	// finding the node before last key
-	for i := 0; i < len(paths)-1; i++ {
-		currentKey := paths[i]
-		if len(currentKey) < 1 {
-			// ignore empty slots
-			continue
-		}
-
-		switch reflect.TypeOf(jumper).Kind() {
-		case reflect.Slice:
-			idx, err := ParseJsonPathArrayIndex(currentKey)
-			if err != nil {
-				return errors.Wrap(err, fmt.Sprintf("arror parse array index: %s", currentKey))
-			}
-			jumperHolder := jumper.([]interface{})
-			if int(idx) >= len(jumperHolder) {
-				return fmt.Errorf("array index out of bounds: %s", currentKey)
-			}
-			if jumperHolder[idx] == nil {
-				jumperHolder[idx] = make(map[string]interface{})
-			}
-			jumperBackNode = jumper
-			jumper = jumperHolder[idx]
-		case reflect.Map:
-			jumperBackNode = jumper
-			jumperHolder := jumper.(map[string]interface{})
-			nextJumper, ok := jumperHolder[currentKey]
-			if !ok {
-				// Auto create missing nodes
-				nextKey := paths[i+1]
-				idx, err := ParseJsonPathArrayIndex(nextKey)
-				if err == nil && idx > -1 {
-					// 1. .missing_property.[idx];  add an array element
-					jumperHolder[currentKey] = make([]interface{}, idx+1)
-				} else {
-					// 2. .missing_property.other_property; add an object element
-					jumperHolder[currentKey] = make(map[string]interface{})
-				} //if: end processing missing nodes
-
-				jumper = jumperHolder[currentKey]
-			} else {
-				// jump to next node
-				jumper = nextJumper
-			}
-		default:
-			return fmt.Errorf("element cannot be indexed: %s", currentKey)
-		}
-	} // end finding last node
-
-	// processing last key and set the value
-	lastKey := paths[len(paths)-1]
-
-	// when append array is set, the jumper must be a map
-	if appendArray {
-		jumperObj, ok := jumper.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("append array failed because the node before last node is not an object")
-		}
-
-		if jumperObj[lastKey] == nil {
-			jumperObj[lastKey] = make([]interface{}, 0)
-		}
+	path, err := compileJsonPathCached(jsonPath)
+	if err != nil {
+		return err
+	}
+	return path.Set(object, value, appendArray)
+}
 
-		lastNodeType := reflect.TypeOf(jumperObj[lastKey]).Kind()
-		if lastNodeType != reflect.Slice {
-			return fmt.Errorf("append array failed because last node is not an array: %s", lastNodeType.String())
-		}
+// InsertNestedField inserts value into the array field located by `jsonPath` at the position.
+// The array is created if missing, a nil position appends value to the end like SetNestedField with appendArray.
+func InsertNestedField(object map[string]interface{}, jsonPath string, value interface{}, position *ArrayInsertPosition) error {
+	if jsonPath == "" {
+		return errors.New("param jsonPath is empty")
+	}
 
-		targetArr := jumperObj[lastKey].([]interface{})
+	path, err := compileJsonPathCached(jsonPath)
+	if err != nil {
+		return err
+	}
+	return path.Insert(object, value, position)
+}
 
-		switch reflect.TypeOf(value).Kind() {
-		case reflect.Slice:
-			sliceValue := reflect.ValueOf(value)
-			newSlice := jumperObj[lastKey].([]interface{})
-			for i := 0; i < sliceValue.Len(); i++ {
-				newSlice = append(newSlice, sliceValue.Index(i).Interface())
-			}
-			jumperObj[lastKey] = newSlice
-		default:
-			jumperObj[lastKey] = append(targetArr, value)
-		}
+// MergeNestedField deep merges value into the field located by `jsonPath` with JSON merge patch (RFC 7386) semantics,
+// null values in `value` delete the corresponding keys.
+func MergeNestedField(object map[string]interface{}, jsonPath string, value interface{}) error {
+	path, err := compileJsonPathCached(jsonPath)
+	if err != nil {
+		return err
+	}
+	return path.Merge(object, value)
+}
 
-		return nil
+// StrategicMergeNestedField merges value into the field located by `jsonPath` like a Kubernetes strategic merge patch,
+// elements of known lists (containers, env, ports, volumes...) are merged by their keys instead of being duplicated.
+func StrategicMergeNestedField(object map[string]interface{}, jsonPath string, value interface{}) error {
+	path, err := compileJsonPathCached(jsonPath)
+	if err != nil {
+		return err
 	}
+	return path.StrategicMerge(object, value)
+}
 
-	// set last node
-	jumperType := reflect.TypeOf(jumper).Kind()
-	if jumperType == reflect.Slice {
-		// 1. fixed index array
-		idx, err := ParseJsonPathArrayIndex(lastKey)
-		if err != nil {
-			return fmt.Errorf("cannot set object property inside an array")
-		}
-		jumperArray := jumper.([]interface{})
-		backNode := jumperBackNode.(map[string]interface{})
-		if len(jumperArray) < int(idx+1) {
-			return fmt.Errorf("cannot modify the target array: index out of bounds: %s", lastKey)
-		}
-		jumperArray[idx] = value
-		backNode[paths[len(paths)-2]] = jumperArray
-		return nil
+// RemoveNestedField removes the fields located by `jsonPath` from the object and returns the count of removed nodes.
+// Removing a missing field is not an error for multi-match paths, while for single-match paths
+// an error caused by ErrFieldNotExist is returned if a parent field is missing.
+func RemoveNestedField(object map[string]interface{}, jsonPath string) (int, error) {
+	if jsonPath == "" {
+		return 0, errors.New("param jsonPath is empty")
 	}
-	// 2. normal object
-	jumberObj, ok := jumper.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("element cannot be indexed: %s", strings.Join(paths[0:len(paths)-1], "."))
+
+	path, err := compileJsonPathCached(jsonPath)
+	if err != nil {
+		return 0, err
 	}
-	jumberObj[lastKey] = value
-	return nil
+	return path.Remove(object)
 }
 
 func ParseJsonPathArrayIndex(idxExp string) (int64, error) {
@@ -227,6 +152,9 @@ func deepCopyJSONValuebackup(x interface{}) interface{} {
 }
 
 func DeepCopyJSONValue(x interface{}) interface{} {
+	if x == nil {
+		return nil
+	}
 	val := reflect.ValueOf(x)
 	switch val.Kind() {
 	case reflect.Map:
@@ -235,7 +163,7 @@ func DeepCopyJSONValue(x interface{}) interface{} {
 		}
 		clone := reflect.MakeMap(val.Type())
 		for _, k := range val.MapKeys() {
-			clone.SetMapIndex(k, reflect.ValueOf(DeepCopyJSONValue(val.MapIndex(k).Interface())))
+			clone.SetMapIndex(k, deepCopyReflectValue(val.MapIndex(k), val.Type().Elem()))
 		}
 		return clone.Interface()
 	case reflect.Slice:
@@ -244,7 +172,7 @@ func DeepCopyJSONValue(x interface{}) interface{} {
 		}
 		clone := reflect.MakeSlice(val.Type(), val.Len(), val.Cap())
 		for i := 0; i < val.Len(); i++ {
-			clone.Index(i).Set(reflect.ValueOf(DeepCopyJSONValue(val.Index(i).Interface())))
+			clone.Index(i).Set(deepCopyReflectValue(val.Index(i), val.Type().Elem()))
 		}
 		return clone.Interface()
 	case reflect.String, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8, reflect.Int, reflect.Bool, reflect.Float64, reflect.Float32:
@@ -256,3 +184,60 @@ func DeepCopyJSONValue(x interface{}) interface{} {
 		panic(fmt.Errorf("cannot deep copy %T", x))
 	}
 }
+
+// deepCopyReflectValue deep copies an element of a map or slice, nil elements are kept as the zero value of elemType.
+func deepCopyReflectValue(v reflect.Value, elemType reflect.Type) reflect.Value {
+	copied := DeepCopyJSONValue(v.Interface())
+	if copied == nil {
+		return reflect.Zero(elemType)
+	}
+	return reflect.ValueOf(copied)
+}
+
+// jsonValueEqual deeply compares two json values, integer and float numbers of the same value are equal.
+func jsonValueEqual(a interface{}, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			if bvv, exists := bv[k]; !exists || !jsonValueEqual(v, bvv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonValueEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if an, ok := toFloat64(a); ok {
+		bn, ok := toFloat64(b)
+		return ok && an == bn
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat64 converts a number of any type to float64.
+func toFloat64(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
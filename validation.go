@@ -0,0 +1,335 @@
+package structemplate
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Codes of ParamValueError
+const (
+	ErrCodeRequired          = "Required"          // 必填参数缺失
+	ErrCodeInvalidType       = "InvalidType"       // 参数值不能转换为ValueDataType
+	ErrCodeUnknownDataType   = "UnknownDataType"   // ValueDataType未知
+	ErrCodeNotInOptions      = "NotInOptions"      // 不可自定义的参数值不在可选值中
+	ErrCodePattern           = "Pattern"           // Limit为正则表达式
+	ErrCodeMinimum           = "Minimum"           // Limit为最小值
+	ErrCodeMaximum           = "Maximum"           // Limit为最大值
+	ErrCodeMinLength         = "MinLength"         // Limit为最小长度
+	ErrCodeMaxLength         = "MaxLength"         // Limit为最大长度
+	ErrCodeMinItems          = "MinItems"          // Limit为最少元素个数
+	ErrCodeMaxItems          = "MaxItems"          // Limit为最多元素个数
+	ErrCodeUniqueItems       = "UniqueItems"       // 数组元素重复
+	ErrCodeEnum              = "Enum"              // 不是枚举值之一
+	ErrCodeFormat            = "Format"            // Limit为格式名称
+	ErrCodeInvalidConstraint = "InvalidConstraint" // 约束定义有误, 如正则表达式无效
+	ErrCodeTargetNotMatched  = "TargetNotMatched"  // 注入目标没有匹配到任何对象
+	ErrCodeInvalidJsonPath   = "InvalidJsonPath"   // 注入目标的JsonPath无效
+	ErrCodeInvalidSelector   = "InvalidSelector"   // 注入目标的对象选择器无效
+	ErrCodeStrSlotMissing    = "StrSlotMissing"    // StrSlot变量缺少值
+	ErrCodeUnsafeValue       = "UnsafeValue"       // StrSlot变量的值无法安全转义
+	ErrCodeRequiredIf        = "RequiredIf"        // 满足RequiredIf条件的参数缺少值, Reason为条件
+	ErrCodeMissingDependency = "MissingDependency" // Limit为缺少值的依赖参数
+	ErrCodeConflict          = "Conflict"          // Limit为同时设定的冲突参数
+	ErrCodeInvalidCondition  = "InvalidCondition"  // 条件定义有误, 如循环依赖
+	ErrCodeInvalidExpression = "InvalidExpression" // CEL表达式无效或循环引用, Limit为表达式
+	ErrCodeExpressionFailed  = "ExpressionFailed"  // CEL表达式求值失败, Limit为表达式
+	ErrCodeRuleViolated      = "RuleViolated"      // 校验规则不满足, Limit为规则, Reason为规则的错误信息
+	ErrCodeGenerateFailed    = "GenerateFailed"    // 参数值生成失败, Limit为生成器类型
+)
+
+// Languages of validation messages
+const (
+	LangEnglish = "en"
+	LangChinese = "zh"
+)
+
+// validationMessages holds the message formats of error codes by language. The placeholders {param}, {type},
+// {path}, {target}, {limit} and {reason} are replaced with the fields of the error.
+var validationMessages = map[string]map[string]string{
+	ErrCodeRequired: {
+		LangEnglish: "param {param} is required",
+		LangChinese: "必填参数缺失: {param}",
+	},
+	ErrCodeInvalidType: {
+		LangEnglish: "value of param {param} is not a valid {type}: {reason}",
+		LangChinese: "参数{param}的值不是有效的{type}类型",
+	},
+	ErrCodeUnknownDataType: {
+		LangEnglish: "param {param} has an unknown data type {type}",
+		LangChinese: "参数{param}的数据类型{type}未知",
+	},
+	ErrCodeNotInOptions: {
+		LangEnglish: "value of param {param} is not one of the available options",
+		LangChinese: "参数{param}的值不在可选值范围内",
+	},
+	ErrCodePattern: {
+		LangEnglish: "value of param {param} must match the pattern {limit}",
+		LangChinese: "参数{param}的值须匹配正则表达式{limit}",
+	},
+	ErrCodeMinimum: {
+		LangEnglish: "value of param {param} must be greater than or equal to {limit}",
+		LangChinese: "参数{param}的值不能小于{limit}",
+	},
+	ErrCodeMaximum: {
+		LangEnglish: "value of param {param} must be less than or equal to {limit}",
+		LangChinese: "参数{param}的值不能大于{limit}",
+	},
+	ErrCodeMinLength: {
+		LangEnglish: "length of param {param} must be at least {limit}",
+		LangChinese: "参数{param}的长度不能小于{limit}",
+	},
+	ErrCodeMaxLength: {
+		LangEnglish: "length of param {param} must be at most {limit}",
+		LangChinese: "参数{param}的长度不能大于{limit}",
+	},
+	ErrCodeMinItems: {
+		LangEnglish: "param {param} requires at least {limit} items",
+		LangChinese: "参数{param}至少需要{limit}个元素",
+	},
+	ErrCodeMaxItems: {
+		LangEnglish: "param {param} allows at most {limit} items",
+		LangChinese: "参数{param}最多允许{limit}个元素",
+	},
+	ErrCodeUniqueItems: {
+		LangEnglish: "items of param {param} must be unique: {reason}",
+		LangChinese: "参数{param}的元素不能重复",
+	},
+	ErrCodeEnum: {
+		LangEnglish: "value of param {param} must be one of the enum values",
+		LangChinese: "参数{param}的值须为枚举值之一",
+	},
+	ErrCodeFormat: {
+		LangEnglish: "value of param {param} is not a valid {limit}: {reason}",
+		LangChinese: "参数{param}的值不符合{limit}格式",
+	},
+	ErrCodeInvalidConstraint: {
+		LangEnglish: "param {param} has an invalid constraint: {reason}",
+		LangChinese: "参数{param}的约束定义有误: {reason}",
+	},
+	ErrCodeTargetNotMatched: {
+		LangEnglish: "no object matches the inject target of param {param}: {target}",
+		LangChinese: "参数{param}的注入目标没有匹配到任何对象: {target}",
+	},
+	ErrCodeInvalidJsonPath: {
+		LangEnglish: "json path {path} of param {param} is invalid: {reason}",
+		LangChinese: "参数{param}的JsonPath {path} 无效: {reason}",
+	},
+	ErrCodeInvalidSelector: {
+		LangEnglish: "inject target of param {param} has an invalid selector: {reason}",
+		LangChinese: "参数{param}的注入目标选择器无效: {reason}",
+	},
+	ErrCodeStrSlotMissing: {
+		LangEnglish: "StrSlot variable {param} has no value ({reason})",
+		LangChinese: "StrSlot变量{param}缺少值({reason})",
+	},
+	ErrCodeUnsafeValue: {
+		LangEnglish: "value of StrSlot variable {param} cannot be safely escaped: {reason}",
+		LangChinese: "StrSlot变量{param}的值无法安全转义: {reason}",
+	},
+	ErrCodeRequiredIf: {
+		LangEnglish: "param {param} is required when {reason}",
+		LangChinese: "满足条件 {reason} 时参数{param}必填",
+	},
+	ErrCodeMissingDependency: {
+		LangEnglish: "param {param} requires param {limit} to be set",
+		LangChinese: "设定参数{param}时必须同时设定参数{limit}",
+	},
+	ErrCodeConflict: {
+		LangEnglish: "param {param} conflicts with param {limit}",
+		LangChinese: "参数{param}不能与参数{limit}同时设定",
+	},
+	ErrCodeInvalidCondition: {
+		LangEnglish: "param {param} has an invalid condition: {reason}",
+		LangChinese: "参数{param}的条件定义有误: {reason}",
+	},
+	ErrCodeInvalidExpression: {
+		LangEnglish: "expression {limit} is invalid: {reason}",
+		LangChinese: "表达式{limit}无效: {reason}",
+	},
+	ErrCodeExpressionFailed: {
+		LangEnglish: "evaluation of expression {limit} failed: {reason}",
+		LangChinese: "表达式{limit}求值失败: {reason}",
+	},
+	ErrCodeRuleViolated: {
+		LangEnglish: "validation rule failed: {reason}",
+		LangChinese: "校验规则不满足: {reason}",
+	},
+	ErrCodeGenerateFailed: {
+		LangEnglish: "cannot generate a {limit} value for param {param}: {reason}",
+		LangChinese: "无法为参数{param}生成{limit}类型的值: {reason}",
+	},
+}
+
+// element suffixes of errors of array elements by language
+var validationElementMessages = map[string]string{
+	LangEnglish: " (element %d)",
+	LangChinese: "(第%d个元素)",
+}
+
+var validationMessagesLock sync.RWMutex
+
+// RegisterValidationMessage registers the message format of an error code in a language,
+// see validationMessages for the placeholders. Messages of unknown languages fall back to English.
+func RegisterValidationMessage(code string, lang string, format string) {
+	validationMessagesLock.Lock()
+	defer validationMessagesLock.Unlock()
+	if validationMessages[code] == nil {
+		validationMessages[code] = make(map[string]string)
+	}
+	validationMessages[code][lang] = format
+}
+
+func lookupValidationMessage(code string, lang string) string {
+	validationMessagesLock.RLock()
+	defer validationMessagesLock.RUnlock()
+	formats := validationMessages[code]
+	if format, ok := formats[lang]; ok {
+		return format
+	}
+	if format, ok := formats[LangEnglish]; ok {
+		return format
+	}
+	return "invalid value of param {param}: {reason}"
+}
+
+// ParamValueError is a structured validation error of a param.
+type ParamValueError struct {
+	Code      string               `json:"code"` // 机器可读的错误码, 见ErrCode*常量
+	ParamCode string               `json:"paramCode"`
+	DataType  string               `json:"dataType,omitempty"`
+	Target    *JsonPathParamTarget `json:"target,omitempty"`   // 出错的注入目标
+	JsonPath  string               `json:"jsonPath,omitempty"` // 出错的JsonPath
+	Index     *int                 `json:"index,omitempty"`    // 出错的数组元素下标
+	Value     interface{}          `json:"value,omitempty"`
+	Limit     interface{}          `json:"limit,omitempty"`  // 违反的约束, 如最大值、正则表达式、格式名称
+	Reason    string               `json:"reason,omitempty"` // 英文的详细原因
+}
+
+func (e *ParamValueError) Error() string {
+	return e.Message(LangEnglish)
+}
+
+// Message returns the message of the error in the language, LangEnglish or LangChinese
+// or any language registered with RegisterValidationMessage.
+func (e *ParamValueError) Message(lang string) string {
+	target := ""
+	if e.Target != nil {
+		target = e.Target.String()
+	}
+	limit := ""
+	if e.Limit != nil {
+		limit = fmt.Sprint(e.Limit)
+	}
+	replacer := strings.NewReplacer(
+		"{param}", e.ParamCode,
+		"{type}", e.DataType,
+		"{path}", e.JsonPath,
+		"{target}", target,
+		"{limit}", limit,
+		"{reason}", e.Reason,
+	)
+	msg := replacer.Replace(lookupValidationMessage(e.Code, lang))
+	if e.Index != nil {
+		suffix, ok := validationElementMessages[lang]
+		if !ok {
+			suffix = validationElementMessages[LangEnglish]
+		}
+		msg += fmt.Sprintf(suffix, *e.Index)
+	}
+	return msg
+}
+
+// ParamValueErrors collects all the validation errors found in a validation pass.
+type ParamValueErrors []*ParamValueError
+
+func (e ParamValueErrors) Error() string {
+	return strings.Join(e.Messages(LangEnglish), "; ")
+}
+
+// Messages returns the messages of all the errors in the language.
+func (e ParamValueErrors) Messages(lang string) []string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Message(lang))
+	}
+	return msgs
+}
+
+// ByParam groups the errors by param code.
+func (e ParamValueErrors) ByParam() map[string]ParamValueErrors {
+	result := make(map[string]ParamValueErrors)
+	for _, err := range e {
+		result[err.ParamCode] = append(result[err.ParamCode], err)
+	}
+	return result
+}
+
+// ValidateJsonPathParams checks the JsonPath and JsonPatch params against the objects without modifying them,
+// and returns all the problems at once as a ParamValueErrors: missing required values, invalid json paths or
+// selectors of inject targets and inject targets matching no object.
+// Values are not validated, see ValidateParamValues. With a nil objsMap, e.g. when the manifest cannot be rendered,
+// inject targets are not matched against objects. Params hidden by their VisibleIf condition are skipped, see ActiveParams.
+func ValidateJsonPathParams(objsMap map[schema.GroupVersionKind][]*unstructured.Unstructured, paramsDef []TemplateDynamicParam, valuesMap map[string]interface{}) error {
+	paramsDef, err := ActiveParams(paramsDef, valuesMap)
+	if vErr := validateJsonPathParams(objsMap, paramsDef, valuesMap); vErr != nil {
+		if err == nil {
+			return vErr
+		}
+		return append(err.(ParamValueErrors), vErr.(ParamValueErrors)...)
+	}
+	return err
+}
+
+// validateJsonPathParams validates the params resolved by ActiveParams
+func validateJsonPathParams(objsMap map[schema.GroupVersionKind][]*unstructured.Unstructured, paramsDef []TemplateDynamicParam, valuesMap map[string]interface{}) error {
+	errs := make(ParamValueErrors, 0)
+	for i := range paramsDef {
+		param := &paramsDef[i]
+		if param.ParamType != ParamTypeJsonPath && param.ParamType != ParamTypeJsonPatch {
+			continue
+		}
+
+		value, exist := valuesMap[param.ParamCode]
+		if !exist {
+			value = param.Default
+		}
+		if value == nil {
+			if !param.Optional {
+				errs = append(errs, &ParamValueError{Code: ErrCodeRequired, ParamCode: param.ParamCode})
+			}
+			continue
+		}
+
+		for j := range param.ValueInjectTargets {
+			target := &param.ValueInjectTargets[j]
+			if param.ParamType == ParamTypeJsonPath {
+				if _, err := compileJsonPathCached(target.ParamJsonPath); err != nil {
+					errs = append(errs, &ParamValueError{Code: ErrCodeInvalidJsonPath, ParamCode: param.ParamCode, Target: target,
+						JsonPath: target.ParamJsonPath, Reason: err.Error()})
+					continue
+				}
+			}
+			if objsMap == nil {
+				continue
+			}
+			targetObjs, err := SelectTargetObjects(objsMap[target.TargetGVK], target)
+			if err != nil {
+				errs = append(errs, &ParamValueError{Code: ErrCodeInvalidSelector, ParamCode: param.ParamCode, Target: target,
+					JsonPath: target.ParamJsonPath, Reason: err.Error()})
+				continue
+			}
+			if len(targetObjs) < 1 && !param.Optional {
+				errs = append(errs, &ParamValueError{Code: ErrCodeTargetNotMatched, ParamCode: param.ParamCode, Target: target,
+					JsonPath: target.ParamJsonPath})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package structemplate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParamValueError_Message(t *testing.T) {
+	index := 2
+	err := &ParamValueError{Code: ErrCodeMaximum, ParamCode: "REPLICAS", Index: &index, Limit: 5.0}
+	if msg := err.Message(LangEnglish); msg != "value of param REPLICAS must be less than or equal to 5 (element 2)" {
+		t.Logf("Unexpected english message: %s", msg)
+		t.FailNow()
+		return
+	}
+	if msg := err.Message(LangChinese); msg != "参数REPLICAS的值不能大于5(第2个元素)" {
+		t.Logf("Unexpected chinese message: %s", msg)
+		t.FailNow()
+		return
+	}
+	if err.Message("fr") != err.Error() {
+		t.Logf("Unknown languages should fall back to english: %s", err.Message("fr"))
+		t.FailNow()
+		return
+	}
+
+	RegisterValidationMessage(ErrCodeRequired, "fr", "le paramètre {param} est obligatoire")
+	if msg := (&ParamValueError{Code: ErrCodeRequired, ParamCode: "NAME"}).Message("fr"); msg != "le paramètre NAME est obligatoire" {
+		t.Logf("Unexpected registered message: %s", msg)
+		t.FailNow()
+		return
+	}
+
+	b, _ := json.Marshal(err)
+	if string(b) != `{"code":"Maximum","paramCode":"REPLICAS","index":2,"limit":5}` {
+		t.Logf("Unexpected json: %s", b)
+		t.FailNow()
+		return
+	}
+}
+
+func TestValidateParamValues_Codes(t *testing.T) {
+	params := []TemplateDynamicParam{
+		{ParamCode: "PORT", ValueDataType: DataTypeInt},
+		{ParamCode: "SIZE", ValueDataType: "size"},
+		{ParamCode: "TIER", ValueDataType: DataTypeString, AvailableOptions: []interface{}{"gold", "silver"}},
+		{ParamCode: "NAME", ValueDataType: DataTypeString, Constraints: &ParamConstraints{Pattern: "("}},
+	}
+	_, err := ValidateParamValues(params, ParamValuesMap{"PORT": "http", "SIZE": 1, "TIER": "bronze", "NAME": "a"})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 4 {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+	for i, code := range []string{ErrCodeInvalidType, ErrCodeUnknownDataType, ErrCodeNotInOptions, ErrCodeInvalidConstraint} {
+		if errs[i].Code != code {
+			t.Logf("Unexpected code of error %d: %s", i, errs[i].Code)
+			t.FailNow()
+			return
+		}
+	}
+}
+
+func TestTemplateValidate(t *testing.T) {
+	deployGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	tmpl := NewTemplate("app", templateManifest, []TemplateDynamicParam{
+		{ParamCode: "APP_NAME", ParamType: ParamTypeStrSlot},
+		{ParamCode: "REPLICAS", ParamType: ParamTypeJsonPath, ValueDataType: DataTypeInt,
+			Constraints:        &ParamConstraints{Maximum: floatPtr(5)},
+			ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: deployGVK, ParamJsonPath: ".spec.replicas"}}},
+		{ParamCode: "IMAGE", ParamType: ParamTypeJsonPath,
+			ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: deployGVK, ParamJsonPath: ".spec.template.spec.containers[0].image"}}},
+		{ParamCode: "LABEL", ParamType: ParamTypeJsonPath,
+			ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: schema.GroupVersionKind{Version: "v1", Kind: "Service"}, ParamJsonPath: ".metadata.labels.app"}}},
+	})
+	tmpl.StrSlotOptions.MissingKeyMode = StrSlotMissingKeyError
+
+	err := tmpl.Validate(ParamValuesMap{"REPLICAS": 8, "LABEL": "demo"})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 4 {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+	expected := []struct {
+		code  string
+		param string
+	}{
+		{ErrCodeMaximum, "REPLICAS"},
+		{ErrCodeStrSlotMissing, "APP_NAME"},
+		{ErrCodeStrSlotMissing, "APP_NAME"},
+		{ErrCodeRequired, "IMAGE"},
+	}
+	for i, e := range expected {
+		if errs[i].Code != e.code || errs[i].ParamCode != e.param {
+			t.Logf("Unexpected error %d: %s %s", i, errs[i].Code, errs[i].ParamCode)
+			t.FailNow()
+			return
+		}
+	}
+
+	err = tmpl.Validate(ParamValuesMap{"APP_NAME": "demo", "REPLICAS": 3, "IMAGE": "nginx", "LABEL": "demo"})
+	errs, ok = err.(ParamValueErrors)
+	if !ok || len(errs) != 1 || errs[0].Code != ErrCodeTargetNotMatched || errs[0].Target == nil || errs[0].JsonPath != ".metadata.labels.app" {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+	if len(errs.Messages(LangChinese)) != 1 || len(errs.ByParam()["LABEL"]) != 1 {
+		t.Logf("Unexpected messages: %v", errs.Messages(LangChinese))
+		t.FailNow()
+		return
+	}
+
+	if err := tmpl.Validate(ParamValuesMap{"APP_NAME": "demo", "REPLICAS": 3, "IMAGE": "nginx"}); err == nil {
+		t.Logf("Missing required LABEL should be reported")
+		t.FailNow()
+		return
+	}
+}
+
+func TestTemplateValidate_Defaults(t *testing.T) {
+	deployGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	tmpl := NewTemplate("app", templateManifest, []TemplateDynamicParam{
+		{ParamCode: "APP_NAME", ParamType: ParamTypeStrSlot, Default: "Demo",
+			Constraints: &ParamConstraints{Format: FormatDNS1123Label}},
+		{ParamCode: "REPLICAS", ParamType: ParamTypeJsonPath, ValueDataType: DataTypeInt, Default: 10,
+			Constraints:        &ParamConstraints{Maximum: floatPtr(5)},
+			ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: deployGVK, ParamJsonPath: ".spec.replicas"}}},
+		{ParamCode: "IMAGE", ParamType: ParamTypeJsonPath, Default: "busybox",
+			Options:            []ParamOption{{Value: "nginx"}},
+			ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: deployGVK, ParamJsonPath: ".spec.template.spec.containers[0].image"}}},
+	})
+
+	err := tmpl.Validate(ParamValuesMap{})
+	errs, ok := err.(ParamValueErrors)
+	if !ok || len(errs) != 3 || errs[0].Code != ErrCodeFormat || errs[1].Code != ErrCodeMaximum || errs[2].Code != ErrCodeNotInOptions ||
+		!strings.HasPrefix(errs[1].Reason, "invalid default value") {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+
+	// the defaults of params with values are not used
+	if err := tmpl.Validate(ParamValuesMap{"APP_NAME": "demo", "REPLICAS": 3, "IMAGE": "nginx"}); err != nil {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}
+
+func TestRenderJsonPathParams_RequiredError(t *testing.T) {
+	manifestObjs, err := LoadManifestsFromString(templateManifest)
+	if err != nil {
+		t.Logf("Failed load manifests: %+v", err)
+		t.FailNow()
+		return
+	}
+	params := []TemplateDynamicParam{{ParamCode: "REPLICAS", ParamType: ParamTypeJsonPath,
+		ValueInjectTargets: []JsonPathParamTarget{{TargetGVK: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, ParamJsonPath: ".spec.replicas"}}}}
+	err = RenderJsonPathParams(manifestObjs.ObjectsMap, params, map[string]interface{}{})
+	if e, ok := err.(*ParamValueError); !ok || e.Code != ErrCodeRequired || e.Message(LangChinese) != "必填参数缺失: REPLICAS" {
+		t.Logf("Unexpected error: %v", err)
+		t.FailNow()
+		return
+	}
+}